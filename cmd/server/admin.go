@@ -0,0 +1,515 @@
+package main
+
+import (
+	apiKeyDomain "RedColarTest/internal/apikeys/domain"
+	apiKeyRepo "RedColarTest/internal/apikeys/repository"
+	apiKeyServices "RedColarTest/internal/apikeys/services"
+	"RedColarTest/internal/cache"
+	"RedColarTest/internal/common"
+	"RedColarTest/internal/configs"
+	incidentDomain "RedColarTest/internal/incident/domain"
+	"RedColarTest/internal/incident/repository"
+	"RedColarTest/internal/incident/services"
+	"RedColarTest/internal/logging"
+	tenant "RedColarTest/internal/tenants/domain"
+	tenantRepo "RedColarTest/internal/tenants/repository"
+	tenantServices "RedColarTest/internal/tenants/services"
+	"RedColarTest/internal/webhook"
+	webhookRepo "RedColarTest/internal/webhook/repository"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+)
+
+// adminEnv holds the services admin commands work through, so they follow
+// the same validation, cache invalidation and webhook events as the API.
+type adminEnv struct {
+	incidents *services.IncidentService
+	keys      *apiKeyServices.APIKeyService
+	tenants   *tenantServices.TenantService
+	queue     *webhook.Queue
+	// cacheBackend is the server cache.backend setting.
+	cacheBackend string
+}
+
+// adminResult is what a command prints: data for -o json, rows for tables.
+type adminResult struct {
+	data   any
+	header []string
+	rows   [][]string
+}
+
+type adminRun func(ctx context.Context, env *adminEnv) (adminResult, error)
+
+type adminCmd struct {
+	name  string
+	usage string
+	// setup registers the command flags and returns the command bound to them.
+	setup func(fs *flag.FlagSet) adminRun
+}
+
+var adminCommands = []adminCmd{
+	{name: "incident list", usage: "list incidents", setup: adminIncidentList},
+	{name: "incident create", usage: "create an incident", setup: adminIncidentCreate},
+	{name: "incident deactivate", usage: "deactivate one incident", setup: adminIncidentDeactivate},
+	{name: "incident deactivate-area", usage: "deactivate all active incidents within a radius of a point", setup: adminIncidentDeactivateArea},
+	{name: "cache flush", usage: "drop the cached active incidents", setup: adminCacheFlush},
+	{name: "queue stats", usage: "show webhook queue depth and retries", setup: adminQueueStats},
+	{name: "queue peek", usage: "show the next webhook deliveries and retries", setup: adminQueuePeek},
+	{name: "apikey list", usage: "list API keys", setup: adminAPIKeyList},
+	{name: "apikey mint", usage: "create an API key and print its secret once", setup: adminAPIKeyMint},
+	{name: "apikey revoke", usage: "revoke an API key", setup: adminAPIKeyRevoke},
+	{name: "apikey rotate", usage: "replace an API key and print the new secret once", setup: adminAPIKeyRotate},
+	{name: "tenant list", usage: "list tenants", setup: adminTenantList},
+}
+
+// adminCommand implements "admin <resource> <action>": routine operator tasks
+// run directly against Postgres and Redis with table or JSON output.
+func adminCommand(args []string) int {
+	var cmd *adminCmd
+	if len(args) >= 2 {
+		for i := range adminCommands {
+			if adminCommands[i].name == args[0]+" "+args[1] {
+				cmd = &adminCommands[i]
+			}
+		}
+	}
+	if cmd == nil {
+		adminUsage(os.Stderr)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("admin "+cmd.name, flag.ExitOnError)
+	format := fs.String("o", "table", "output format: table or json")
+	tenantRef := fs.String("tenant", "", "tenant slug or id (default tenant when empty)")
+	run := cmd.setup(fs)
+	cfg, err := configs.Load(fs, args[2:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", *format)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	env, closeEnv, err := newAdminEnv(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closeEnv()
+
+	ctx = tenant.WithID(ctx, tenant.DefaultID)
+	if *tenantRef != "" {
+		id, active := env.tenants.ResolveTenant(ctx, *tenantRef)
+		if id == 0 {
+			fmt.Fprintf(os.Stderr, "unknown tenant %q\n", *tenantRef)
+			return 1
+		}
+		if !active {
+			fmt.Fprintf(os.Stderr, "warning: tenant %q is inactive\n", *tenantRef)
+		}
+		ctx = tenant.WithID(ctx, id)
+	}
+
+	res, err := run(ctx, env)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := writeAdminResult(os.Stdout, *format, res); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func adminUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: server admin <resource> <action> [-o table|json] [-tenant ref] [flags]")
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range adminCommands {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.name, c.usage)
+	}
+	_ = tw.Flush()
+}
+
+func newAdminEnv(ctx context.Context, cfg configs.Config) (*adminEnv, func(), error) {
+	// Services log failures themselves; keep that off stdout and terse.
+	logger, err := logging.New(os.Stderr, logging.Config{Level: "warn", Format: "text"})
+	if err != nil {
+		return nil, nil, err
+	}
+	pool, err := pgxpool.New(ctx, cfg.Database.URL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     cfg.Redis.Addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		pool.Close()
+		_ = redisClient.Close()
+		return nil, nil, fmt.Errorf("connect to redis: %w", err)
+	}
+
+	// The queue is only used to enqueue events; the server workers deliver them.
+	queue := webhook.NewQueue(redisClient, webhookRepo.NewSubscriptionRepo(pool, logger), cfg.Webhook.URL,
+		webhookQueueOptions(cfg.Webhook, logger, nil))
+	// An in-process cache belongs to the server process; flushing it from
+	// here would be a no-op, so the admin tools always act on Redis. Version
+	// bumps reach the servers' in-process copies through the bus.
+	versions := cache.NewBus(redisClient, cache.BusOptions{Logger: logger})
+	env := &adminEnv{
+		incidents: services.NewIncidentService(repository.NewIncidentRepo(pool, logger), cache.NewRedis(redisClient), versions, queue, logger),
+		keys:      apiKeyServices.NewAPIKeyService(apiKeyRepo.NewAPIKeyRepo(pool, logger)),
+		tenants:   tenantServices.NewTenantService(tenantRepo.NewTenantRepo(pool, logger), nil),
+		queue:     queue,
+
+		cacheBackend: cfg.Cache.Backend,
+	}
+	return env, func() {
+		_ = redisClient.Close()
+		pool.Close()
+	}, nil
+}
+
+func writeAdminResult(w io.Writer, format string, res adminResult) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res.data)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if len(res.header) > 0 {
+		fmt.Fprintln(tw, strings.Join(res.header, "\t"))
+	}
+	for _, row := range res.rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// serviceErr converts a service error without turning a nil *common.Error
+// into a non-nil error.
+func serviceErr(err *common.Error) error {
+	if err == nil {
+		return nil
+	}
+	return err
+}
+
+func adminIncidentList(fs *flag.FlagSet) adminRun {
+	all := fs.Bool("all", false, "include inactive incidents")
+	page := fs.Int("page", 1, "page number")
+	pageSize := fs.Int("page-size", 50, "incidents per page (max 200)")
+	return func(ctx context.Context, env *adminEnv) (adminResult, error) {
+		items, total, p, size, err := env.incidents.List(ctx, *page, *pageSize, !*all)
+		if err != nil {
+			return adminResult{}, err
+		}
+		res := incidentsResult(items)
+		res.data = map[string]any{"items": items, "total": total, "page": p, "page_size": size}
+		return res, nil
+	}
+}
+
+func adminIncidentCreate(fs *flag.FlagSet) adminRun {
+	title := fs.String("title", "", "incident title")
+	description := fs.String("description", "", "incident description")
+	lat := fs.Float64("lat", 0, "latitude")
+	lon := fs.Float64("lon", 0, "longitude")
+	radius := fs.Int("radius", 100, "danger radius in meters")
+	expiresIn := fs.Duration("expires-in", 0, "deactivate automatically after this long, e.g. 2h")
+	return func(ctx context.Context, env *adminEnv) (adminResult, error) {
+		in := incidentDomain.Incident{
+			Title:         *title,
+			Latitude:      *lat,
+			Longitude:     *lon,
+			DangerRadiusM: *radius,
+			IsActive:      true,
+		}
+		if *description != "" {
+			in.Description = description
+		}
+		if *expiresIn > 0 {
+			at := time.Now().Add(*expiresIn).UTC()
+			in.ExpiresAt = &at
+		}
+		out, err := env.incidents.Create(ctx, in)
+		if err != nil {
+			return adminResult{}, err
+		}
+		res := incidentsResult([]incidentDomain.Incident{*out})
+		res.data = out
+		return res, nil
+	}
+}
+
+func adminIncidentDeactivate(fs *flag.FlagSet) adminRun {
+	id := fs.Int64("id", 0, "incident id")
+	return func(ctx context.Context, env *adminEnv) (adminResult, error) {
+		out, err := env.incidents.Deactivate(ctx, *id)
+		if err != nil {
+			return adminResult{}, err
+		}
+		res := incidentsResult([]incidentDomain.Incident{out})
+		res.data = out
+		return res, nil
+	}
+}
+
+func adminIncidentDeactivateArea(fs *flag.FlagSet) adminRun {
+	lat := fs.Float64("lat", 0, "latitude of the area centre")
+	lon := fs.Float64("lon", 0, "longitude of the area centre")
+	radius := fs.Float64("radius", 0, "area radius in meters")
+	return func(ctx context.Context, env *adminEnv) (adminResult, error) {
+		items, err := env.incidents.DeactivateArea(ctx, *lat, *lon, *radius)
+		if err != nil {
+			return adminResult{}, err
+		}
+		return incidentsResult(items), nil
+	}
+}
+
+func incidentsResult(items []incidentDomain.Incident) adminResult {
+	res := adminResult{
+		data:   items,
+		header: []string{"ID", "TENANT", "TITLE", "LAT", "LON", "RADIUS_M", "ACTIVE", "EXPIRES_AT", "UPDATED_AT"},
+	}
+	for _, it := range items {
+		res.rows = append(res.rows, []string{
+			strconv.FormatInt(it.ID, 10),
+			strconv.FormatInt(it.TenantID, 10),
+			it.Title,
+			strconv.FormatFloat(it.Latitude, 'f', -1, 64),
+			strconv.FormatFloat(it.Longitude, 'f', -1, 64),
+			strconv.Itoa(it.DangerRadiusM),
+			strconv.FormatBool(it.IsActive),
+			formatTime(it.ExpiresAt),
+			formatTime(&it.UpdatedAt),
+		})
+	}
+	return res
+}
+
+func adminCacheFlush(fs *flag.FlagSet) adminRun {
+	allTenants := fs.Bool("all-tenants", false, "flush the cache of every tenant")
+	return func(ctx context.Context, env *adminEnv) (adminResult, error) {
+		if env.cacheBackend == cache.BackendMemory {
+			return adminResult{}, fmt.Errorf("cache.backend is %s: the cache lives in each server process and expires after cache.incidents_ttl_seconds", env.cacheBackend)
+		}
+		tenantID, cerr := tenant.Require(ctx)
+		if cerr != nil {
+			return adminResult{}, cerr
+		}
+		ids := []int64{tenantID}
+		if *allTenants {
+			list, err := env.tenants.List(ctx)
+			if err != nil {
+				return adminResult{}, err
+			}
+			ids = ids[:0]
+			for _, t := range list {
+				ids = append(ids, t.ID)
+			}
+		}
+		res := adminResult{header: []string{"TENANT", "FLUSHED"}}
+		for _, id := range ids {
+			if err := serviceErr(env.incidents.FlushCache(tenant.WithID(ctx, id))); err != nil {
+				return adminResult{}, fmt.Errorf("tenant %d: %w", id, err)
+			}
+			res.rows = append(res.rows, []string{strconv.FormatInt(id, 10), "true"})
+		}
+		res.data = map[string]any{"tenants": ids}
+		return res, nil
+	}
+}
+
+func adminQueueStats(*flag.FlagSet) adminRun {
+	return func(ctx context.Context, env *adminEnv) (adminResult, error) {
+		stats, err := env.queue.Stats(ctx)
+		if err != nil {
+			return adminResult{}, err
+		}
+		return adminResult{
+			data:   map[string]int64{"ready": stats.Ready, "delayed": stats.Delayed},
+			header: []string{"READY", "DELAYED"},
+			rows:   [][]string{{strconv.FormatInt(stats.Ready, 10), strconv.FormatInt(stats.Delayed, 10)}},
+		}, nil
+	}
+}
+
+func adminQueuePeek(fs *flag.FlagSet) adminRun {
+	limit := fs.Int64("limit", 20, "jobs to show from the queue and from the retries each")
+	return func(ctx context.Context, env *adminEnv) (adminResult, error) {
+		jobs, err := env.queue.Peek(ctx, *limit)
+		if err != nil {
+			return adminResult{}, err
+		}
+		res := adminResult{
+			data:   jobs,
+			header: []string{"STATE", "TENANT", "SUBSCRIPTION", "KIND", "EVENTS", "ATTEMPT", "DUE_AT"},
+		}
+		for _, j := range jobs {
+			sub := "legacy"
+			if j.SubscriptionID != 0 {
+				sub = strconv.FormatInt(j.SubscriptionID, 10)
+			}
+			res.rows = append(res.rows, []string{
+				j.State,
+				strconv.FormatInt(j.TenantID, 10),
+				sub,
+				j.Kind,
+				strings.Join(j.EventIDs, ","),
+				strconv.Itoa(j.Attempt),
+				formatTime(j.DueAt),
+			})
+		}
+		return res, nil
+	}
+}
+
+func adminAPIKeyList(*flag.FlagSet) adminRun {
+	return func(ctx context.Context, env *adminEnv) (adminResult, error) {
+		keys, err := env.keys.List(ctx)
+		if err != nil {
+			return adminResult{}, err
+		}
+		return apiKeysResult(keys), nil
+	}
+}
+
+func adminAPIKeyMint(fs *flag.FlagSet) adminRun {
+	name := fs.String("name", "", "key name")
+	owner := fs.String("owner", "", "who the key is for")
+	role := fs.String("role", "", "role granted to the key")
+	scopes := fs.String("scopes", "", "comma-separated scopes: "+strings.Join(apiKeyDomain.KnownScopes, ", "))
+	expiresIn := fs.Duration("expires-in", 0, "key lifetime, e.g. 720h (no expiry when 0)")
+	return func(ctx context.Context, env *adminEnv) (adminResult, error) {
+		in := apiKeyDomain.APIKey{Name: *name, Owner: *owner, Role: *role}
+		for _, s := range strings.Split(*scopes, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				in.Scopes = append(in.Scopes, s)
+			}
+		}
+		if *expiresIn > 0 {
+			at := time.Now().Add(*expiresIn).UTC()
+			in.ExpiresAt = &at
+		}
+		key, raw, err := env.keys.Mint(ctx, in)
+		if err != nil {
+			return adminResult{}, err
+		}
+		res := apiKeysResult([]apiKeyDomain.APIKey{key})
+		res.header = append(res.header, "KEY")
+		res.rows[0] = append(res.rows[0], raw)
+		res.data = map[string]any{"api_key": key, "key": raw}
+		return res, nil
+	}
+}
+
+func adminAPIKeyRevoke(fs *flag.FlagSet) adminRun {
+	id := fs.Int64("id", 0, "API key id")
+	return func(ctx context.Context, env *adminEnv) (adminResult, error) {
+		key, err := env.keys.Revoke(ctx, *id)
+		if err != nil {
+			return adminResult{}, err
+		}
+		res := apiKeysResult([]apiKeyDomain.APIKey{key})
+		res.data = key
+		return res, nil
+	}
+}
+
+func adminAPIKeyRotate(fs *flag.FlagSet) adminRun {
+	id := fs.Int64("id", 0, "API key id")
+	grace := fs.Duration("grace", 0, "how long the old key keeps working, e.g. 24h (revoked at once when 0)")
+	return func(ctx context.Context, env *adminEnv) (adminResult, error) {
+		key, raw, err := env.keys.Rotate(ctx, *id, *grace)
+		if err != nil {
+			return adminResult{}, err
+		}
+		res := apiKeysResult([]apiKeyDomain.APIKey{key})
+		res.header = append(res.header, "KEY")
+		res.rows[0] = append(res.rows[0], raw)
+		res.data = map[string]any{"api_key": key, "key": raw}
+		return res, nil
+	}
+}
+
+func apiKeysResult(keys []apiKeyDomain.APIKey) adminResult {
+	res := adminResult{
+		data:   keys,
+		header: []string{"ID", "TENANT", "NAME", "OWNER", "PREFIX", "ROLE", "SCOPES", "EXPIRES_AT", "REVOKED_AT"},
+	}
+	for _, k := range keys {
+		scopes := append([]string(nil), k.Scopes...)
+		sort.Strings(scopes)
+		res.rows = append(res.rows, []string{
+			strconv.FormatInt(k.ID, 10),
+			strconv.FormatInt(k.TenantID, 10),
+			k.Name,
+			k.Owner,
+			k.Prefix,
+			orDash(k.Role),
+			orDash(strings.Join(scopes, ",")),
+			formatTime(k.ExpiresAt),
+			formatTime(k.RevokedAt),
+		})
+	}
+	return res
+}
+
+func adminTenantList(*flag.FlagSet) adminRun {
+	return func(ctx context.Context, env *adminEnv) (adminResult, error) {
+		list, err := env.tenants.List(ctx)
+		if err != nil {
+			return adminResult{}, err
+		}
+		res := adminResult{data: list, header: []string{"ID", "SLUG", "NAME", "ACTIVE"}}
+		for _, t := range list {
+			res.rows = append(res.rows, []string{strconv.FormatInt(t.ID, 10), t.Slug, t.Name, strconv.FormatBool(t.IsActive)})
+		}
+		return res, nil
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -1,115 +1,538 @@
 package main
 
 import (
+	apiKeyHandlers "RedColarTest/internal/apikeys/handlers"
+	apiKeyRepo "RedColarTest/internal/apikeys/repository"
+	apiKeyServices "RedColarTest/internal/apikeys/services"
+	"RedColarTest/internal/cache"
+	clientHandlers "RedColarTest/internal/clients/handlers"
+	clientRepo "RedColarTest/internal/clients/repository"
+	clientServices "RedColarTest/internal/clients/services"
+	"RedColarTest/internal/configs"
 	"RedColarTest/internal/incident/handlers"
 	"RedColarTest/internal/incident/repository"
 	"RedColarTest/internal/incident/services"
+	"RedColarTest/internal/lifecycle"
 	locationHandlers "RedColarTest/internal/locations/handlers"
 	locationRepo "RedColarTest/internal/locations/repository"
 	locationServices "RedColarTest/internal/locations/services"
+	"RedColarTest/internal/logging"
+	"RedColarTest/internal/metrics"
+	"RedColarTest/internal/middleware"
+	"RedColarTest/internal/migrate"
 	"RedColarTest/internal/routes"
 	systemHandlers "RedColarTest/internal/system/handlers"
+	systemServices "RedColarTest/internal/system/services"
+	tenantHandlers "RedColarTest/internal/tenants/handlers"
+	tenantRepo "RedColarTest/internal/tenants/repository"
+	tenantServices "RedColarTest/internal/tenants/services"
+	"RedColarTest/internal/tracing"
 	"RedColarTest/internal/webhook"
+	webhookHandlers "RedColarTest/internal/webhook/handlers"
+	webhookRepo "RedColarTest/internal/webhook/repository"
+	webhookServices "RedColarTest/internal/webhook/services"
 	"context"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
 func main() {
+	envErr := godotenv.Load()
 
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found (ok if using real env vars):", err)
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "config" {
+		os.Exit(configCommand(args[1:]))
 	}
-
-	dsn := getEnv("DATABASE_URL", os.Getenv("database_url"))
-	if dsn == "" {
-		log.Fatal("DATABASE_URL is required")
+	if len(args) > 0 && args[0] == "migrate" {
+		os.Exit(migrateCommand(args[1:]))
 	}
-
-	operatorKey := getEnv("OPERATOR_API_KEY", os.Getenv("operator_api_key"))
-	if operatorKey == "" {
-		log.Fatal("OPERATOR_API_KEY is required")
+	if len(args) > 0 && args[0] == "admin" {
+		os.Exit(adminCommand(args[1:]))
 	}
 
-	statsWindowMinutes := getEnvInt("STATS_TIME_WINDOW_MINUTES", 60)
-	cacheTTLSeconds := getEnvInt("CACHE_INCIDENTS_TTL_SECONDS", 60)
-	webhookURL := getEnv("WEBHOOK_URL", "")
-	webhookMaxRetries := getEnvInt("WEBHOOK_MAX_RETRIES", 5)
-	webhookRetryBaseSeconds := getEnvInt("WEBHOOK_RETRY_BASE_SECONDS", 10)
+	fs := flag.NewFlagSet("server", flag.ExitOnError)
+	pingURL := fs.String("ping-webhook", "", "send a signed ping event to the given URL and exit")
+	pingFormat := fs.String("ping-format", string(webhook.FormatLegacy), "delivery format for -ping-webhook")
+	pingSecret := fs.String("ping-secret", "", "signing secret for -ping-webhook (default webhook.secret)")
+	cfg, cfgErr := configs.Load(fs, args)
 
-	redisAddr := getEnv("REDIS_ADDR", "localhost:6379")
-	redisPassword := getEnv("REDIS_PASSWORD", "")
-	redisDB := getEnvInt("REDIS_DB", 0)
+	// Pinging only needs the webhook settings, so it works without a full config.
+	if *pingURL != "" {
+		if *pingSecret == "" {
+			*pingSecret = cfg.Webhook.Secret
+		}
+		os.Exit(pingWebhook(*pingURL, webhook.Format(*pingFormat), *pingSecret, cfg.Webhook.Timeout))
+	}
+	if cfgErr != nil {
+		fmt.Fprintln(os.Stderr, cfgErr)
+		os.Exit(2)
+	}
+
+	logger, err := logging.New(os.Stdout, logging.Config{Level: cfg.Log.Level, Format: cfg.Log.Format})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Route the standard log package and library output through the same handler.
+	slog.SetDefault(logger)
+	if envErr != nil {
+		logger.Info("no .env file found (ok if using real env vars)", "err", envErr)
+	}
+	if cfg.Auth.OperatorAPIKey == "" {
+		logger.Warn("OPERATOR_API_KEY is not set, only database API keys are accepted")
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	pool, err := pgxpool.New(ctx, dsn)
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+		Stdout:      os.Stdout,
+	})
 	if err != nil {
-		log.Fatal(err)
+		fatal(logger, "set up tracing failed", "err", err)
+	}
+
+	if cfg.Database.AutoMigrate {
+		if err := autoMigrate(cfg.Database, logger); err != nil {
+			fatal(logger, "apply migrations failed", "err", err)
+		}
+	}
+
+	poolConfig, err := pgxpool.ParseConfig(cfg.Database.URL)
+	if err != nil {
+		fatal(logger, "parse DATABASE_URL failed", "err", err)
+	}
+	poolConfig.ConnConfig.Tracer = tracing.PgxTracer{}
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		fatal(logger, "connect to postgres failed", "err", err)
 	}
-	defer pool.Close()
 
 	redisClient := redis.NewClient(&redis.Options{
-		Addr:     redisAddr,
-		Password: redisPassword,
-		DB:       redisDB,
+		Addr:     cfg.Redis.Addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
 	})
+	if err := redisotel.InstrumentTracing(redisClient); err != nil {
+		fatal(logger, "instrument redis failed", "err", err)
+	}
 
-	incRepo := repository.NewIncidentRepo(pool)
-	incSvc := services.NewIncidentService(incRepo, redisClient)
-	incHandler := handlers.NewIncidentHandler(incSvc)
+	appMetrics := metrics.New()
+	appMetrics.Register(metrics.PgxPool(pool), metrics.RedisPool(redisClient))
+
+	subsRepo := webhookRepo.NewSubscriptionRepo(pool, logger)
+	webhookQueue := webhook.NewQueue(redisClient, subsRepo, cfg.Webhook.URL, webhookQueueOptions(cfg.Webhook, logger, appMetrics))
+	appMetrics.Register(metrics.QueueDepth(webhookQueue.Depth))
+	subsSvc := webhookServices.NewSubscriptionService(subsRepo, webhookQueue)
+	subsHandler := webhookHandlers.NewSubscriptionHandler(subsSvc)
 
-	webhookQueue := webhook.NewQueue(redisClient, webhookURL, webhookMaxRetries, time.Duration(webhookRetryBaseSeconds)*time.Second)
+	incRepo := repository.NewIncidentRepo(pool, logger)
+	incidentCache, err := cache.New(cacheConfig(cfg.Cache), redisClient)
+	if err != nil {
+		fatal(logger, "set up cache failed", "err", err)
+	}
+	// Instances sharing Redis agree on cache versions through the bus; the
+	// memory backend is per process, so its versions are too.
+	var cacheVersions cache.Versions = cache.NewLocalVersions()
+	var cacheLocker cache.Locker = cache.NewLocalLocker()
+	var cacheBus *cache.Bus
+	if cfg.Cache.Backend != cache.BackendMemory {
+		cacheLocker = cache.NewRedisLocker(redisClient)
+		cacheBus = cache.NewBus(redisClient, cache.BusOptions{
+			CheckInterval: cfg.Cache.VersionCheck,
+			Logger:        logger,
+			Metrics:       appMetrics,
+		})
+		cacheVersions = cacheBus
+	}
+	incSvc := services.NewIncidentService(incRepo, incidentCache, cacheVersions, webhookQueue, logger)
+	incHandler := handlers.NewIncidentHandler(incSvc)
 
-	localRepo := locationRepo.NewLocationRepo(pool)
+	localRepo := locationRepo.NewLocationRepo(pool, logger)
+	var degraded locationServices.Degraded
+	if cfg.Degraded.SnapshotMaxAge > 0 {
+		degraded.Snapshots = locationServices.NewSnapshots(cfg.Degraded.SnapshotPath, logger)
+		degraded.MaxAge = cfg.Degraded.SnapshotMaxAge
+	}
+	if cfg.Degraded.SpoolMaxLen > 0 {
+		spool := locationRepo.NewRedisSpool(redisClient, int64(cfg.Degraded.SpoolMaxLen))
+		appMetrics.Register(metrics.SpoolDepth(spool.Len))
+		degraded.Spool = spool
+		degraded.Locker = cache.NewRedisLocker(redisClient)
+	}
 	localSvc := locationServices.NewLocationService(
 		localRepo,
 		incRepo,
-		redisClient,
-		time.Duration(cacheTTLSeconds)*time.Second,
+		incidentCache,
+		cacheVersions,
+		cache.RefreshOptions{
+			TTL:      cfg.Cache.IncidentsTTL,
+			StaleTTL: cfg.Cache.StaleTTL,
+			Locker:   cacheLocker,
+			Logger:   logger,
+		},
+		degraded,
 		webhookQueue,
+		appMetrics,
+		logger,
+	)
+	localHandler := locationHandlers.NewLocationHandler(localSvc, cfg.Stats.WindowMinutes, logger)
+	postgresCheck := systemServices.PostgresCheck(pool)
+	// Location checks keep answering from snapshots while Postgres is down, so
+	// draining every replica would only make things worse.
+	postgresCheck.Critical = cfg.Degraded.SnapshotMaxAge == 0
+	healthSvc := systemServices.NewHealthService(cfg.Health.CheckTimeout, cfg.Health.NonCritical,
+		postgresCheck,
+		systemServices.RedisCheck(redisClient),
+		systemServices.WebhookQueueCheck(webhookQueue, cfg.Health.HeartbeatMaxAge),
+		systemServices.CacheCheck(localSvc),
 	)
-	localHandler := locationHandlers.NewLocationHandler(localSvc, statsWindowMinutes)
-	healthHandler := systemHandlers.NewHandler(pool, redisClient)
+	healthHandler := systemHandlers.NewHandler(healthSvc)
+
+	tenantSvc := tenantServices.NewTenantService(tenantRepo.NewTenantRepo(pool, logger), cacheVersions)
+	tenantHandler := tenantHandlers.NewTenantHandler(tenantSvc)
+
+	clientSvc := clientServices.NewClientService(clientRepo.NewClientRepo(pool, logger))
+	clientHandler := clientHandlers.NewClientHandler(clientSvc)
+
+	keyRepo := apiKeyRepo.NewAPIKeyRepo(pool, logger)
+	keySvc := apiKeyServices.NewAPIKeyService(keyRepo)
+	keyHandler := apiKeyHandlers.NewAPIKeyHandler(keySvc)
+
+	validators := middleware.MultiAPIKeyValidator{}
+	if cfg.Auth.OperatorAPIKey != "" {
+		validators = append(validators, middleware.StaticAPIKeyValidator{Expected: cfg.Auth.OperatorAPIKey})
+	}
+	validators = append(validators, keySvc)
+
+	var jwtVerifier *middleware.JWTVerifier
+	if jwtCfg := cfg.Auth.JWT; jwtCfg.JWKS != "" {
+		jwks := middleware.NewJWKS(jwtCfg.JWKS, jwtCfg.JWKSRefresh)
+		if err := jwks.Load(ctx); err != nil {
+			fatal(logger, "load JWKS failed", "err", err)
+		}
+		jwtVerifier = middleware.NewJWTVerifier(jwks, middleware.JWTConfig{
+			Issuer:      jwtCfg.Issuer,
+			Audience:    jwtCfg.Audience,
+			RolesClaim:  jwtCfg.RolesClaim,
+			RoleMapping: jwtCfg.RoleMapping,
+			TenantClaim: jwtCfg.TenantClaim,
+		})
+	}
+
+	clientAuth := middleware.ClientAuth(clientSvc, redisClient, middleware.ClientAuthConfig{
+		Mode:    cfg.Auth.ClientAuthMode,
+		MaxSkew: cfg.Auth.ClientAuthMaxSkew,
+		Logger:  logger,
+	})
 
-	r := routes.NewRouter(routes.RouterDeps{
+	r, err := routes.NewRouter(routes.RouterDeps{
 		IncidentHandler: incHandler,
 		LocationHandler: localHandler,
 		HealthHandler:   healthHandler,
-		OperatorKey:     operatorKey,
+		WebhookHandler:  subsHandler,
+		APIKeyHandler:   keyHandler,
+		TenantHandler:   tenantHandler,
+		ClientHandler:   clientHandler,
+		APIKeyValidator: validators,
+		JWTVerifier:     jwtVerifier,
+		TenantResolver:  tenantSvc,
+		ClientAuth:      clientAuth,
+		RateLimiter:     middleware.NewRateLimiter(redisClient, logger),
+		RateLimits: routes.RateLimits{
+			CheckPerIP:   cfg.RateLimits.LocationCheckIP,
+			CheckPerUser: cfg.RateLimits.LocationCheckUser,
+			Operator:     cfg.RateLimits.Operator,
+		},
+		TrustedProxies: cfg.HTTP.TrustedProxies,
+		Idempotency:    middleware.NewIdempotency(redisClient, cfg.Idempotency.TTL, logger),
+		Logger:         logger,
+		Metrics:        appMetrics,
+		ServiceName:    cfg.Tracing.ServiceName,
 	})
+	if err != nil {
+		fatal(logger, "build router failed", "err", err)
+	}
+
+	workers := lifecycle.NewGroup()
+	workers.Go(webhookQueue.Run)
+	if cacheBus != nil {
+		workers.Go(cacheBus.Run)
+	}
+	workers.Go(func(ctx context.Context) {
+		incSvc.RunExpirer(ctx, cfg.Incidents.ExpiryCheckInterval)
+	})
+	workers.Go(func(ctx context.Context) {
+		localSvc.RunSpoolReplay(ctx, cfg.Degraded.SpoolReplay)
+	})
+	if degraded.Snapshots != nil {
+		workers.Go(func(ctx context.Context) {
+			degraded.Snapshots.Run(ctx, snapshotFlushInterval)
+		})
+	}
 
-	go webhookQueue.Run(context.Background())
+	srv := &http.Server{
+		Addr:              cfg.HTTP.Addr,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// /metrics has its own listener so it is not exposed with the public API.
+	var metricsSrv *http.Server
+	if cfg.HTTP.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", appMetrics.Handler())
+		metricsSrv = &http.Server{
+			Addr:              cfg.HTTP.MetricsAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+	}
 
-	addr := ":8080"
-	log.Println("listening on", addr)
-	if err := r.Run(addr); err != nil {
-		log.Fatal(err)
+	// Stop order: no new requests, then finish work they started, then the
+	// workers that consume it, and only then the clients everything uses.
+	app := lifecycle.New(logger, cfg.HTTP.ShutdownTimeout)
+	app.OnStop("readiness", func(ctx context.Context) error {
+		healthSvc.SetStopping()
+		select {
+		case <-time.After(cfg.HTTP.ShutdownDelay):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	app.OnStop("http server", srv.Shutdown)
+	app.OnStop("location checks", localHandler.Wait)
+	app.OnStop("cache refresh", localSvc.Wait)
+	app.OnStop("background workers", workers.Stop)
+	if metricsSrv != nil {
+		app.OnStop("metrics server", metricsSrv.Shutdown)
 	}
+	app.OnStop("tracing", shutdownTracing)
+	app.OnStop("redis", func(context.Context) error { return redisClient.Close() })
+	app.OnStop("postgres", func(context.Context) error {
+		pool.Close()
+		return nil
+	})
+
+	app.Go("http server", func() error {
+		logger.Info("listening", "addr", srv.Addr)
+		return srv.ListenAndServe()
+	})
+	if metricsSrv != nil {
+		app.Go("metrics server", func() error {
+			logger.Info("serving metrics", "addr", metricsSrv.Addr)
+			return metricsSrv.ListenAndServe()
+		})
+	}
+	if err := app.Run(context.Background()); err != nil {
+		fatal(logger, "server stopped with error", "err", err)
+	}
+	logger.Info("server stopped")
+}
+
+// snapshotFlushInterval is how often incident snapshots are written to
+// degraded.snapshot_path.
+const snapshotFlushInterval = 30 * time.Second
+
+func cacheConfig(cfg configs.Cache) cache.Config {
+	return cache.Config{
+		Backend:         cfg.Backend,
+		LocalMaxEntries: cfg.LocalMaxEntries,
+		LocalTTL:        cfg.LocalTTL,
+	}
+}
+
+func webhookQueueOptions(cfg configs.Webhook, logger *slog.Logger, m *metrics.Metrics) webhook.QueueOptions {
+	return webhook.QueueOptions{
+		Workers:                cfg.Workers,
+		DestinationConcurrency: cfg.DestinationConcurrency,
+		MaxRetries:             cfg.MaxRetries,
+		RetryBase:              cfg.RetryBase,
+		RetryMax:               cfg.RetryMax,
+		RequestTimeout:         cfg.Timeout,
+		BreakerThreshold:       cfg.BreakerThreshold,
+		BreakerCooldown:        cfg.BreakerCooldown,
+		Secret:                 cfg.Secret,
+		BatchWindow:            cfg.BatchWindow,
+		BatchMaxSize:           cfg.BatchMaxSize,
+		DigestCooldown:         cfg.DigestCooldown,
+		Logger:                 logger,
+		Metrics:                m,
+	}
+}
+
+func fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
 }
 
-func getEnv(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// configCommand implements "config print": it dumps the effective
+// configuration with secrets redacted and reports validation errors.
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: server config print [-config file] [flags]")
+		return 2
+	}
+	cfg, err := configs.Load(flag.NewFlagSet("config print", flag.ExitOnError), args[1:])
+	if printErr := cfg.Print(os.Stdout); printErr != nil {
+		fmt.Fprintln(os.Stderr, printErr)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// autoMigrate applies pending migrations before anything else touches the
+// database. Replicas starting together wait on the migration lock, and a
+// termination signal aborts the wait.
+func autoMigrate(db configs.Database, logger *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, db.MigrateTimeout)
+	defer cancel()
+
+	m, err := migrate.New(db.URL, logger)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	if err := m.Up(ctx); err != nil {
+		return err
+	}
+	st, err := m.Status(ctx)
+	if err != nil {
+		return err
 	}
-	return def
+	logger.Info("database schema is up to date", "version", st.Version)
+	return nil
 }
 
-func getEnvInt(key string, def int) int {
-	raw := os.Getenv(key)
-	if raw == "" {
-		return def
+// migrateCommand implements "migrate up|down|status|force". Like ping it only
+// needs database.url, so other configuration errors do not block it.
+func migrateCommand(args []string) int {
+	const usage = "usage: server migrate up | down [N|all] | status | force VERSION [-config file] [flags]"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	action, args := args[0], args[1:]
+	var operand string
+	if (action == "down" || action == "force") && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		operand, args = args[0], args[1:]
+	}
+
+	steps := 1
+	var version int
+	switch action {
+	case "up", "status":
+	case "down":
+		switch operand {
+		case "":
+		case "all":
+			steps = 0
+		default:
+			n, err := strconv.Atoi(operand)
+			if err != nil || n <= 0 {
+				fmt.Fprintf(os.Stderr, "invalid step count %q\n", operand)
+				return 2
+			}
+			steps = n
+		}
+	case "force":
+		n, err := strconv.Atoi(operand)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "force needs a version, e.g. server migrate force 11")
+			return 2
+		}
+		version = n
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+
+	cfg, cfgErr := configs.Load(flag.NewFlagSet("migrate "+action, flag.ExitOnError), args)
+	if cfg.Database.URL == "" {
+		fmt.Fprintln(os.Stderr, cfgErr)
+		return 2
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, cfg.Database.MigrateTimeout)
+	defer cancel()
+
+	m, err := migrate.New(cfg.Database.URL, logger)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer m.Close()
+
+	switch action {
+	case "up":
+		err = m.Up(ctx)
+	case "down":
+		err = m.Down(ctx, steps)
+	case "force":
+		err = m.Force(ctx, version)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	st, err := m.Status(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	current := "none"
+	if st.Applied {
+		current = strconv.FormatUint(uint64(st.Version), 10)
+	}
+	fmt.Printf("version: %s\ndirty: %t\nlatest: %d\npending: %d\n", current, st.Dirty, st.Latest, len(st.Pending))
+	if st.Dirty {
+		return 1
+	}
+	return 0
+}
+
+func pingWebhook(target string, format webhook.Format, secret string, timeout time.Duration) int {
+	if !format.Valid() {
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", format)
+		return 2
+	}
+	q := webhook.NewQueue(nil, nil, "", webhook.QueueOptions{
+		RequestTimeout: timeout,
+	})
+	res, err := q.PingURL(context.Background(), target, format, secret)
+	if err != nil && res.StatusCode == 0 {
+		fmt.Fprintf(os.Stderr, "ping failed after %dms: %v\n", res.Latency.Milliseconds(), err)
+		return 1
 	}
-	v, err := strconv.Atoi(raw)
+	fmt.Printf("status: %d\nlatency_ms: %d\nbody: %s\n", res.StatusCode, res.Latency.Milliseconds(), res.Body)
 	if err != nil {
-		return def
+		return 1
 	}
-	return v
+	return 0
 }
@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	ScopeIncidentsRead  = "incidents:read"
+	ScopeIncidentsWrite = "incidents:write"
+	ScopeStatsRead      = "stats:read"
+	ScopeWebhooksAdmin  = "webhooks:admin"
+	ScopeAPIKeysAdmin   = "apikeys:admin"
+)
+
+var KnownScopes = []string{
+	ScopeIncidentsRead,
+	ScopeIncidentsWrite,
+	ScopeStatsRead,
+	ScopeWebhooksAdmin,
+	ScopeAPIKeysAdmin,
+}
+
+func IsKnownScope(scope string) bool {
+	return slices.Contains(KnownScopes, scope)
+}
+
+type APIKey struct {
+	ID         int64      `json:"id"`
+	TenantID   int64      `json:"tenant_id"`
+	Name       string     `json:"name"`
+	Owner      string     `json:"owner"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Role       string     `json:"role,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (k APIKey) Usable(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
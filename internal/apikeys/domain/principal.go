@@ -0,0 +1,69 @@
+package domain
+
+import "slices"
+
+const (
+	RoleViewer     = "viewer"
+	RoleDispatcher = "dispatcher"
+	RoleAdmin      = "admin"
+)
+
+// rolePermissions is the permission matrix: viewers read, dispatchers also
+// manage incidents, admins additionally manage API keys and webhooks.
+var rolePermissions = map[string][]string{
+	RoleViewer: {
+		ScopeIncidentsRead,
+		ScopeStatsRead,
+	},
+	RoleDispatcher: {
+		ScopeIncidentsRead,
+		ScopeStatsRead,
+		ScopeIncidentsWrite,
+	},
+	RoleAdmin: {
+		ScopeIncidentsRead,
+		ScopeStatsRead,
+		ScopeIncidentsWrite,
+		ScopeWebhooksAdmin,
+		ScopeAPIKeysAdmin,
+	},
+}
+
+func IsKnownRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Principal is the authenticated caller: either an API key (KeyID set) or a
+// bearer token subject.
+type Principal struct {
+	KeyID   int64    `json:"key_id,omitempty"`
+	Subject string   `json:"subject,omitempty"`
+	Name    string   `json:"name"`
+	Roles   []string `json:"roles,omitempty"`
+	Scopes  []string `json:"scopes"`
+	// TenantID binds the caller to one tenant (API keys); TenantRef is an
+	// unresolved slug or id taken from a token claim. Platform callers are
+	// bound to no tenant and pick one with X-Tenant-ID.
+	TenantID  int64  `json:"tenant_id,omitempty"`
+	TenantRef string `json:"tenant,omitempty"`
+	Platform  bool   `json:"platform,omitempty"`
+}
+
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// Allowed reports whether the principal holds the permission, either as an
+// explicit scope or through one of its roles.
+func (p Principal) Allowed(permission string) bool {
+	if p.HasScope(permission) {
+		return true
+	}
+	for _, role := range p.Roles {
+		if slices.Contains(rolePermissions[role], permission) {
+			return true
+		}
+	}
+	return false
+}
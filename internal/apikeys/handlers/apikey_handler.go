@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"RedColarTest/internal/apikeys/domain"
+	"RedColarTest/internal/apikeys/services"
+	"RedColarTest/internal/common"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	svc *services.APIKeyService
+}
+
+func NewAPIKeyHandler(svc *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{svc: svc}
+}
+
+type mintAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Owner     string     `json:"owner" binding:"required"`
+	Role      string     `json:"role"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (h *APIKeyHandler) Mint(c *gin.Context) {
+	var req mintAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	out, raw, err := h.svc.Mint(c.Request.Context(), domain.APIKey{
+		Name:      req.Name,
+		Owner:     req.Owner,
+		Role:      req.Role,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		if err.Code == common.CodeNotValid {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		if err.Code == common.CodeUnavailable {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"api_key": out, "key": raw})
+}
+
+func (h *APIKeyHandler) List(c *gin.Context) {
+	items, err := h.svc.List(c.Request.Context())
+	if err != nil {
+		if err.Code == common.CodeUnavailable {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	out, errorDto := h.svc.Revoke(c.Request.Context(), id)
+	if errorDto != nil {
+		if errorDto.Code == common.CodeNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		if errorDto.Code == common.CodeUnavailable {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": errorDto.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errorDto.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+type rotateAPIKeyRequest struct {
+	GraceSeconds int64 `json:"grace_seconds"`
+}
+
+func (h *APIKeyHandler) Rotate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req rotateAPIKeyRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	out, raw, errorDto := h.svc.Rotate(c.Request.Context(), id, time.Duration(req.GraceSeconds)*time.Second)
+	if errorDto != nil {
+		switch errorDto.Code {
+		case common.CodeNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		case common.CodeNotValid:
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": errorDto.Error()})
+		case common.CodeUnavailable:
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": errorDto.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": errorDto.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"api_key": out, "key": raw})
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"RedColarTest/internal/apikeys/domain"
+	"RedColarTest/internal/common"
+	"RedColarTest/internal/logging"
+	tenant "RedColarTest/internal/tenants/domain"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const apiKeyColumns = `id, tenant_id, name, owner, prefix, key_hash, coalesce(role, ''), scopes, expires_at, last_used_at, revoked_at, created_at`
+
+type APIKeyRepo struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewAPIKeyRepo(db *pgxpool.Pool, logger *slog.Logger) *APIKeyRepo {
+	return &APIKeyRepo{db: db, log: logging.OrDiscard(logger)}
+}
+
+func (r *APIKeyRepo) Create(ctx context.Context, in domain.APIKey) (domain.APIKey, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return domain.APIKey{}, cerr
+	}
+	const q = `
+insert into api_keys (tenant_id, name, owner, prefix, key_hash, role, scopes, expires_at)
+values ($1, $2, $3, $4, $5, nullif($6, ''), $7, $8)
+returning ` + apiKeyColumns + `;
+`
+	out, err := scanAPIKey(r.db.QueryRow(ctx, q, tenantID, in.Name, in.Owner, in.Prefix, in.KeyHash, in.Role, in.Scopes, in.ExpiresAt))
+	if err != nil {
+		return domain.APIKey{}, common.Internal(ctx, r.log, "APIKeyRepo.Create", err)
+	}
+	return out, nil
+}
+
+func (r *APIKeyRepo) GetByPrefix(ctx context.Context, prefix string) (domain.APIKey, *common.Error) {
+	const q = `select ` + apiKeyColumns + ` from api_keys where prefix = $1;`
+	out, err := scanAPIKey(r.db.QueryRow(ctx, q, prefix))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.APIKey{}, common.NewError(common.CodeNotFound, err.Error())
+	}
+	if err != nil {
+		return domain.APIKey{}, common.Internal(ctx, r.log, "APIKeyRepo.GetByPrefix", err)
+	}
+	return out, nil
+}
+
+func (r *APIKeyRepo) Get(ctx context.Context, id int64) (domain.APIKey, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return domain.APIKey{}, cerr
+	}
+	const q = `select ` + apiKeyColumns + ` from api_keys where id = $1 and tenant_id = $2;`
+	out, err := scanAPIKey(r.db.QueryRow(ctx, q, id, tenantID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.APIKey{}, common.NewError(common.CodeNotFound, err.Error())
+	}
+	if err != nil {
+		return domain.APIKey{}, common.Internal(ctx, r.log, "APIKeyRepo.Get", err)
+	}
+	return out, nil
+}
+
+func (r *APIKeyRepo) List(ctx context.Context) ([]domain.APIKey, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return nil, cerr
+	}
+	const q = `select ` + apiKeyColumns + ` from api_keys where tenant_id = $1 order by id desc;`
+	rows, err := r.db.Query(ctx, q, tenantID)
+	if err != nil {
+		return nil, common.Internal(ctx, r.log, "APIKeyRepo.List", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.APIKey, 0)
+	for rows.Next() {
+		it, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, common.Internal(ctx, r.log, "APIKeyRepo.List", err)
+		}
+		items = append(items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, common.Internal(ctx, r.log, "APIKeyRepo.List", err)
+	}
+	return items, nil
+}
+
+func (r *APIKeyRepo) Revoke(ctx context.Context, id int64) (domain.APIKey, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return domain.APIKey{}, cerr
+	}
+	const q = `
+update api_keys
+set revoked_at = now()
+where id = $1 and tenant_id = $2 and revoked_at is null
+returning ` + apiKeyColumns + `;
+`
+	out, err := scanAPIKey(r.db.QueryRow(ctx, q, id, tenantID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.APIKey{}, common.NewError(common.CodeNotFound, err.Error())
+	}
+	if err != nil {
+		return domain.APIKey{}, common.Internal(ctx, r.log, "APIKeyRepo.Revoke", err)
+	}
+	return out, nil
+}
+
+// ExpireAt shortens a key's lifetime to at; a later existing expiry is never extended.
+func (r *APIKeyRepo) ExpireAt(ctx context.Context, id int64, at time.Time) (domain.APIKey, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return domain.APIKey{}, cerr
+	}
+	const q = `
+update api_keys
+set expires_at = least(coalesce(expires_at, $3), $3)
+where id = $1 and tenant_id = $2 and revoked_at is null
+returning ` + apiKeyColumns + `;
+`
+	out, err := scanAPIKey(r.db.QueryRow(ctx, q, id, tenantID, at))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.APIKey{}, common.NewError(common.CodeNotFound, err.Error())
+	}
+	if err != nil {
+		return domain.APIKey{}, common.Internal(ctx, r.log, "APIKeyRepo.ExpireAt", err)
+	}
+	return out, nil
+}
+
+func (r *APIKeyRepo) TouchLastUsed(ctx context.Context, id int64) *common.Error {
+	const q = `update api_keys set last_used_at = now() where id = $1;`
+	if _, err := r.db.Exec(ctx, q, id); err != nil {
+		return common.Internal(ctx, r.log, "APIKeyRepo.TouchLastUsed", err)
+	}
+	return nil
+}
+
+func scanAPIKey(row pgx.Row) (domain.APIKey, error) {
+	var out domain.APIKey
+	err := row.Scan(
+		&out.ID,
+		&out.TenantID,
+		&out.Name,
+		&out.Owner,
+		&out.Prefix,
+		&out.KeyHash,
+		&out.Role,
+		&out.Scopes,
+		&out.ExpiresAt,
+		&out.LastUsedAt,
+		&out.RevokedAt,
+		&out.CreatedAt,
+	)
+	return out, err
+}
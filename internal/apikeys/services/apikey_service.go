@@ -0,0 +1,172 @@
+package services
+
+import (
+	"RedColarTest/internal/apikeys/domain"
+	"RedColarTest/internal/apikeys/repository"
+	"RedColarTest/internal/common"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	keyPrefix      = "rc"
+	lastUsedPeriod = time.Minute
+)
+
+type APIKeyService struct {
+	repo *repository.APIKeyRepo
+}
+
+func NewAPIKeyService(repo *repository.APIKeyRepo) *APIKeyService {
+	return &APIKeyService{repo: repo}
+}
+
+// Mint creates a key for the context tenant and returns it together with the
+// raw secret, which is only ever shown once. Keys look like rc_<prefix>_<secret>.
+func (s *APIKeyService) Mint(ctx context.Context, in domain.APIKey) (domain.APIKey, string, *common.Error) {
+	if err := validateAPIKey(in); err != nil {
+		return domain.APIKey{}, "", common.NewError(common.CodeNotValid, err.Error())
+	}
+
+	prefix, err := randomHex(4)
+	if err != nil {
+		return domain.APIKey{}, "", common.NewError(common.CodeIternalErr, err.Error())
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return domain.APIKey{}, "", common.NewError(common.CodeIternalErr, err.Error())
+	}
+	raw := keyPrefix + "_" + prefix + "_" + secret
+
+	in.Prefix = prefix
+	in.KeyHash = hashKey(raw)
+	if in.Scopes == nil {
+		in.Scopes = []string{}
+	}
+	out, repoErr := s.repo.Create(ctx, in)
+	if repoErr != nil {
+		return domain.APIKey{}, "", repoErr
+	}
+	return out, raw, nil
+}
+
+func (s *APIKeyService) List(ctx context.Context) ([]domain.APIKey, *common.Error) {
+	return s.repo.List(ctx)
+}
+
+func (s *APIKeyService) Revoke(ctx context.Context, id int64) (domain.APIKey, *common.Error) {
+	if id <= 0 {
+		return domain.APIKey{}, common.NewError(common.CodeNotValid, fmt.Sprintf("API key with id %d not found", id))
+	}
+	return s.repo.Revoke(ctx, id)
+}
+
+// Rotate mints a replacement for key id with the same name, owner, role,
+// scopes and expiry. The old key is revoked at once when grace is zero,
+// otherwise it keeps working for grace so callers can switch over.
+func (s *APIKeyService) Rotate(ctx context.Context, id int64, grace time.Duration) (domain.APIKey, string, *common.Error) {
+	if id <= 0 {
+		return domain.APIKey{}, "", common.NewError(common.CodeNotValid, fmt.Sprintf("API key with id %d not found", id))
+	}
+	if grace < 0 {
+		return domain.APIKey{}, "", common.NewError(common.CodeNotValid, "grace must not be negative")
+	}
+	old, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return domain.APIKey{}, "", err
+	}
+	if !old.Usable(time.Now()) {
+		return domain.APIKey{}, "", common.NewError(common.CodeNotFound, fmt.Sprintf("API key with id %d is revoked or expired", id))
+	}
+
+	out, raw, err := s.Mint(ctx, domain.APIKey{
+		Name:      old.Name,
+		Owner:     old.Owner,
+		Role:      old.Role,
+		Scopes:    old.Scopes,
+		ExpiresAt: old.ExpiresAt,
+	})
+	if err != nil {
+		return domain.APIKey{}, "", err
+	}
+
+	if grace == 0 {
+		_, err = s.repo.Revoke(ctx, id)
+	} else {
+		_, err = s.repo.ExpireAt(ctx, id, time.Now().Add(grace).UTC())
+	}
+	if err != nil && err.Code != common.CodeNotFound {
+		return domain.APIKey{}, "", err
+	}
+	return out, raw, nil
+}
+
+// Validate implements middleware.APIKeyValidator.
+func (s *APIKeyService) Validate(ctx context.Context, rawKey string) (domain.Principal, bool) {
+	parts := strings.SplitN(rawKey, "_", 3)
+	if len(parts) != 3 || parts[0] != keyPrefix {
+		return domain.Principal{}, false
+	}
+	key, err := s.repo.GetByPrefix(ctx, parts[1])
+	if err != nil {
+		return domain.Principal{}, false
+	}
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashKey(rawKey))) != 1 {
+		return domain.Principal{}, false
+	}
+	now := time.Now()
+	if !key.Usable(now) {
+		return domain.Principal{}, false
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastUsedPeriod {
+		_ = s.repo.TouchLastUsed(ctx, key.ID)
+	}
+	principal := domain.Principal{KeyID: key.ID, Name: key.Name, Scopes: key.Scopes, TenantID: key.TenantID}
+	if key.Role != "" {
+		principal.Roles = []string{key.Role}
+	}
+	return principal, true
+}
+
+func validateAPIKey(in domain.APIKey) error {
+	if in.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if in.Owner == "" {
+		return fmt.Errorf("owner is required")
+	}
+	if in.Role != "" && !domain.IsKnownRole(in.Role) {
+		return fmt.Errorf("unknown role %q", in.Role)
+	}
+	if in.Role == "" && len(in.Scopes) == 0 {
+		return fmt.Errorf("role or at least one scope is required")
+	}
+	for _, scope := range in.Scopes {
+		if !domain.IsKnownScope(scope) {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	if in.ExpiresAt != nil && !in.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+	return nil
+}
+
+func hashKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,198 @@
+// Package apitest boots the full HTTP API on in-memory repositories, cache and
+// webhook queue so handlers, middleware and services can be tested together
+// without Postgres or Redis. Rate limiting and idempotency need Redis and are
+// disabled.
+package apitest
+
+import (
+	apikeys "RedColarTest/internal/apikeys/domain"
+	"RedColarTest/internal/cache"
+	clients "RedColarTest/internal/clients/domain"
+	incidenthandlers "RedColarTest/internal/incident/handlers"
+	increpo "RedColarTest/internal/incident/repository"
+	incservices "RedColarTest/internal/incident/services"
+	lochandlers "RedColarTest/internal/locations/handlers"
+	locrepo "RedColarTest/internal/locations/repository"
+	locservices "RedColarTest/internal/locations/services"
+	"RedColarTest/internal/middleware"
+	"RedColarTest/internal/routes"
+	systemhandlers "RedColarTest/internal/system/handlers"
+	systemservices "RedColarTest/internal/system/services"
+	tenant "RedColarTest/internal/tenants/domain"
+	"RedColarTest/internal/webhook"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OperatorKey is accepted as x-api-key with admin rights on every tenant.
+const OperatorKey = "test-operator-key"
+
+type Options struct {
+	// CacheTTL of the active incidents cache; 0 means one minute, negative
+	// disables caching.
+	CacheTTL           time.Duration
+	StatsWindowMinutes int
+	// APIKeys are extra x-api-key values and the callers they authenticate.
+	APIKeys map[string]apikeys.Principal
+	// Tenants maps X-Tenant-ID values to tenant ids besides the default
+	// tenant, which is always known as "default" and "1".
+	Tenants map[string]int64
+}
+
+// Harness is a running API with its in-memory state exposed for assertions.
+type Harness struct {
+	t        testing.TB
+	Router   *gin.Engine
+	Incident *increpo.MemoryRepo
+	Checks   *locrepo.MemoryRepo
+	Cache    *cache.Local
+	Versions *cache.LocalVersions
+	Spool    *locrepo.MemorySpool
+	Webhooks *webhook.Recorder
+	location *lochandlers.Handler
+
+	locationSvc *locservices.Service
+}
+
+// ReplaySpool replays the location checks spooled while Checks was failing.
+func (h *Harness) ReplaySpool() int {
+	h.t.Helper()
+	n, err := h.locationSvc.ReplaySpool(context.Background())
+	if err != nil {
+		h.t.Fatalf("replay spool: %v", err)
+	}
+	return n
+}
+
+func New(t testing.TB, opts Options) *Harness {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	if opts.CacheTTL == 0 {
+		opts.CacheTTL = time.Minute
+	}
+	if opts.StatsWindowMinutes == 0 {
+		opts.StatsWindowMinutes = 60
+	}
+
+	h := &Harness{
+		t:        t,
+		Incident: increpo.NewMemoryRepo(),
+		Checks:   locrepo.NewMemoryRepo(),
+		Cache:    cache.NewLocal(0),
+		Versions: cache.NewLocalVersions(),
+		Spool:    locrepo.NewMemorySpool(),
+		Webhooks: webhook.NewRecorder(),
+	}
+	incSvc := incservices.NewIncidentService(h.Incident, h.Cache, h.Versions, h.Webhooks, nil)
+	h.locationSvc = locservices.NewLocationService(h.Checks, h.Incident, h.Cache, h.Versions,
+		cache.RefreshOptions{TTL: opts.CacheTTL, Locker: cache.NewLocalLocker()},
+		locservices.Degraded{Snapshots: locservices.NewSnapshots("", nil), MaxAge: time.Hour, Spool: h.Spool},
+		h.Webhooks, nil, nil)
+	h.location = lochandlers.NewLocationHandler(h.locationSvc, opts.StatsWindowMinutes, nil)
+
+	tenants := tenantMap{"default": tenant.DefaultID, strconv.FormatInt(tenant.DefaultID, 10): tenant.DefaultID}
+	for ref, id := range opts.Tenants {
+		tenants[ref] = id
+		tenants[strconv.FormatInt(id, 10)] = id
+	}
+	keys := middleware.MultiAPIKeyValidator{middleware.StaticAPIKeyValidator{Expected: OperatorKey}, keyMap(opts.APIKeys)}
+
+	router, err := routes.NewRouter(routes.RouterDeps{
+		IncidentHandler: incidenthandlers.NewIncidentHandler(incSvc),
+		LocationHandler: h.location,
+		HealthHandler:   systemhandlers.NewHandler(systemservices.NewHealthService(time.Second, nil, systemservices.CacheCheck(h.locationSvc))),
+		APIKeyValidator: keys,
+		TenantResolver:  tenants,
+		ClientAuth:      middleware.ClientAuth(nil, nil, middleware.ClientAuthConfig{Mode: clients.AuthOff}),
+	})
+	if err != nil {
+		t.Fatalf("build router: %v", err)
+	}
+	h.Router = router
+	return h
+}
+
+// Do sends a request with body encoded as JSON (nil for none) and headers
+// given as name, value pairs. Location checks are recorded and caches
+// refreshed in the background, so Do waits for that to finish before
+// returning.
+func (h *Harness) Do(method, path string, body any, headers ...string) *Response {
+	h.t.Helper()
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			h.t.Fatalf("encode request body: %v", err)
+		}
+		r = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, r)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.Router.ServeHTTP(rec, req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.location.Wait(ctx); err != nil {
+		h.t.Fatalf("wait for recorded checks: %v", err)
+	}
+	if err := h.locationSvc.Wait(ctx); err != nil {
+		h.t.Fatalf("wait for cache refresh: %v", err)
+	}
+	return &Response{t: h.t, ResponseRecorder: rec}
+}
+
+// Operator sends the request as the built-in admin operator.
+func (h *Harness) Operator(method, path string, body any, headers ...string) *Response {
+	h.t.Helper()
+	return h.Do(method, path, body, append([]string{"x-api-key", OperatorKey}, headers...)...)
+}
+
+type Response struct {
+	t testing.TB
+	*httptest.ResponseRecorder
+}
+
+// Expect fails the test unless the response has the given status.
+func (r *Response) Expect(status int) *Response {
+	r.t.Helper()
+	if r.Code != status {
+		r.t.Fatalf("status = %d, want %d; body: %s", r.Code, status, r.Body.String())
+	}
+	return r
+}
+
+// JSON decodes the response body into v.
+func (r *Response) JSON(v any) {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body.Bytes(), v); err != nil {
+		r.t.Fatalf("decode response %q: %v", r.Body.String(), err)
+	}
+}
+
+type tenantMap map[string]int64
+
+func (m tenantMap) ResolveTenant(_ context.Context, ref string) (int64, bool) {
+	id, ok := m[ref]
+	return id, ok
+}
+
+type keyMap map[string]apikeys.Principal
+
+func (m keyMap) Validate(_ context.Context, rawKey string) (apikeys.Principal, bool) {
+	p, ok := m[rawKey]
+	return p, ok
+}
@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"RedColarTest/internal/logging"
+	"RedColarTest/internal/metrics"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	invalidationChannel = "cache:invalidate"
+	versionKeyPrefix    = "cache:version:"
+)
+
+type BusOptions struct {
+	// CheckInterval is how often known versions are re-read from Redis to
+	// recover from missed messages.
+	CheckInterval time.Duration
+	Logger        *slog.Logger
+	Metrics       *metrics.Metrics
+}
+
+// Bus is the Versions implementation shared by all instances. Versions live
+// in Redis; Bump increments one and announces it on a pub/sub channel, and
+// Run keeps this instance's copy up to date from those messages and a
+// periodic check. Current is answered from memory, so reads cost no round
+// trip once a scope is known.
+type Bus struct {
+	client *redis.Client
+	opts   BusOptions
+	log    *slog.Logger
+
+	mu       sync.Mutex
+	versions map[string]int64
+}
+
+// bumpVersion increments a scope version. A missing key, either a scope never
+// bumped before or one lost in a Redis restart, starts a new generation at the
+// Redis clock in milliseconds instead of 1, so versions keep growing across
+// restarts and instances that still hold the old version accept the new one.
+var bumpVersion = redis.NewScript(`
+local v = redis.call('INCR', KEYS[1])
+if v == 1 then
+  local t = redis.call('TIME')
+  v = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+  redis.call('SET', KEYS[1], v)
+end
+return v
+`)
+
+type invalidation struct {
+	Scope   string `json:"scope"`
+	Version int64  `json:"version"`
+}
+
+func NewBus(client *redis.Client, opts BusOptions) *Bus {
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = 10 * time.Second
+	}
+	return &Bus{
+		client:   client,
+		opts:     opts,
+		log:      logging.OrDiscard(opts.Logger),
+		versions: make(map[string]int64),
+	}
+}
+
+func (b *Bus) Current(ctx context.Context, scope string) (int64, error) {
+	b.mu.Lock()
+	v, ok := b.versions[scope]
+	b.mu.Unlock()
+	if ok {
+		return v, nil
+	}
+	v, err := b.client.Get(ctx, versionKeyPrefix+scope).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+	b.observe(scope, v, "")
+	return b.known(scope), nil
+}
+
+// Bump fails only if the version could not be incremented; a lost
+// announcement is repaired by the periodic check on other instances.
+func (b *Bus) Bump(ctx context.Context, scope string) (int64, error) {
+	v, err := bumpVersion.Run(ctx, b.client, []string{versionKeyPrefix + scope}).Int64()
+	if err != nil {
+		return 0, err
+	}
+	b.observe(scope, v, "")
+	b.opts.Metrics.ObserveInvalidation(metrics.InvalidationPublish)
+	msg, _ := json.Marshal(invalidation{Scope: scope, Version: v})
+	if err := b.client.Publish(ctx, invalidationChannel, msg).Err(); err != nil {
+		b.log.WarnContext(ctx, "publish cache invalidation failed", "scope", scope, "version", v, "err", err)
+	}
+	return v, nil
+}
+
+// Run applies invalidations from other instances until ctx is done.
+func (b *Bus) Run(ctx context.Context) {
+	sub := b.client.Subscribe(ctx, invalidationChannel)
+	defer sub.Close()
+	msgs := sub.Channel()
+
+	ticker := time.NewTicker(b.opts.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-msgs:
+			if !ok {
+				return
+			}
+			var inv invalidation
+			if err := json.Unmarshal([]byte(m.Payload), &inv); err != nil || inv.Scope == "" {
+				b.log.WarnContext(ctx, "drop malformed cache invalidation", "payload", m.Payload)
+				continue
+			}
+			b.observe(inv.Scope, inv.Version, metrics.InvalidationMessage)
+		case <-ticker.C:
+			b.check(ctx)
+		}
+	}
+}
+
+// check re-reads every known version, catching changes whose message this
+// instance missed, e.g. while its subscription was reconnecting.
+func (b *Bus) check(ctx context.Context) {
+	b.mu.Lock()
+	scopes := make([]string, 0, len(b.versions))
+	keys := make([]string, 0, len(b.versions))
+	for scope := range b.versions {
+		scopes = append(scopes, scope)
+		keys = append(keys, versionKeyPrefix+scope)
+	}
+	b.mu.Unlock()
+	if len(keys) == 0 {
+		return
+	}
+
+	vals, err := b.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		b.log.WarnContext(ctx, "check cache versions failed", "err", err)
+		return
+	}
+	for i, raw := range vals {
+		s, ok := raw.(string)
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			continue
+		}
+		b.observe(scopes[i], v, metrics.InvalidationPoll)
+	}
+}
+
+// observe records v if it is newer than the known version and counts the
+// change under source unless this is the first time the scope is seen.
+// Versions never go back, even across Redis restarts (see bumpVersion), so
+// late messages are no-ops.
+func (b *Bus) observe(scope string, v int64, source string) {
+	b.mu.Lock()
+	cur, ok := b.versions[scope]
+	changed := !ok || v > cur
+	if changed {
+		b.versions[scope] = v
+	}
+	b.mu.Unlock()
+	if changed && ok && source != "" {
+		b.opts.Metrics.ObserveInvalidation(source)
+	}
+}
+
+func (b *Bus) known(scope string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.versions[scope]
+}
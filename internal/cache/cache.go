@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrMiss is returned by Get when the key is absent or has expired.
+var ErrMiss = errors.New("cache miss")
+
+const (
+	BackendRedis   = "redis"
+	BackendMemory  = "memory"
+	BackendTwoTier = "two_tier"
+)
+
+// Cache stores values with a per-entry TTL. Get decodes into dst, a pointer
+// to the type that was stored. Callers scope keys themselves (see
+// tenant.ScopedKey). Values handed out by in-process tiers are shared, so
+// callers must not modify them.
+type Cache interface {
+	Get(ctx context.Context, key string, dst any) error
+	Set(ctx context.Context, key string, value any, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+type Config struct {
+	Backend string
+	// LocalMaxEntries bounds the in-process tier.
+	LocalMaxEntries int
+	// LocalTTL caps how long the two-tier cache serves a value from memory
+	// before going back to Redis.
+	LocalTTL time.Duration
+}
+
+// New builds the cache selected by cfg.Backend. redisClient is only used by
+// the redis and two_tier backends.
+func New(cfg Config, redisClient *redis.Client) (Cache, error) {
+	switch cfg.Backend {
+	case BackendRedis, "":
+		return NewRedis(redisClient), nil
+	case BackendMemory:
+		return NewLocal(cfg.LocalMaxEntries), nil
+	case BackendTwoTier:
+		return NewTwoTier(NewLocal(cfg.LocalMaxEntries), NewRedis(redisClient), cfg.LocalTTL), nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const defaultLocalMaxEntries = 1000
+
+// Local is an in-process LRU cache with per-entry TTL. It keeps values as
+// they were stored, so reads cost no decoding. Expired entries are dropped
+// when they are read or reach the end of the LRU list.
+type Local struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List
+	entries    map[string]*list.Element
+	now        func() time.Time
+}
+
+type localEntry struct {
+	key       string
+	value     any
+	expiresAt time.Time
+}
+
+func NewLocal(maxEntries int) *Local {
+	if maxEntries <= 0 {
+		maxEntries = defaultLocalMaxEntries
+	}
+	return &Local{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+func (l *Local) Get(_ context.Context, key string, dst any) error {
+	l.mu.Lock()
+	e, ok := l.lookup(key)
+	l.mu.Unlock()
+	if !ok {
+		return ErrMiss
+	}
+	return assign(dst, e.value)
+}
+
+func (l *Local) Set(_ context.Context, key string, value any, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e := &localEntry{key: key, value: value}
+	if ttl > 0 {
+		e.expiresAt = l.now().Add(ttl)
+	}
+	if el, ok := l.entries[key]; ok {
+		el.Value = e
+		l.order.MoveToFront(el)
+		return nil
+	}
+	l.entries[key] = l.order.PushFront(e)
+	for l.order.Len() > l.maxEntries {
+		l.remove(l.order.Back())
+	}
+	return nil
+}
+
+func (l *Local) Delete(_ context.Context, keys ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, k := range keys {
+		if el, ok := l.entries[k]; ok {
+			l.remove(el)
+		}
+	}
+	return nil
+}
+
+func (l *Local) Exists(_ context.Context, key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.lookup(key)
+	return ok, nil
+}
+
+// Len returns the number of entries, including expired ones not yet dropped.
+func (l *Local) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+func (l *Local) lookup(key string) (*localEntry, bool) {
+	el, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*localEntry)
+	if !e.expiresAt.IsZero() && !l.now().Before(e.expiresAt) {
+		l.remove(el)
+		return nil, false
+	}
+	l.order.MoveToFront(el)
+	return e, true
+}
+
+func (l *Local) remove(el *list.Element) {
+	l.order.Remove(el)
+	delete(l.entries, el.Value.(*localEntry).key)
+}
+
+// assign stores value in the variable dst points to.
+func assign(dst, value any) error {
+	d := reflect.ValueOf(dst)
+	if d.Kind() != reflect.Pointer || d.IsNil() {
+		return fmt.Errorf("cache: dst must be a non-nil pointer, got %T", dst)
+	}
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		d.Elem().SetZero()
+		return nil
+	}
+	if !v.Type().AssignableTo(d.Elem().Type()) {
+		return fmt.Errorf("cache: cannot load %T into %T", value, dst)
+	}
+	d.Elem().Set(v)
+	return nil
+}
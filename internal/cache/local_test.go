@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLocalEvictsLeastRecentlyUsed(t *testing.T) {
+	l := NewLocal(2)
+	ctx := t.Context()
+	_ = l.Set(ctx, "a", 1, 0)
+	_ = l.Set(ctx, "b", 2, 0)
+	var v int
+	if err := l.Get(ctx, "a", &v); err != nil || v != 1 {
+		t.Fatalf("a = %d, %v", v, err)
+	}
+	_ = l.Set(ctx, "c", 3, 0)
+
+	if err := l.Get(ctx, "b", &v); !errors.Is(err, ErrMiss) {
+		t.Fatalf("b should have been evicted, err = %v", err)
+	}
+	for key, want := range map[string]int{"a": 1, "c": 3} {
+		if err := l.Get(ctx, key, &v); err != nil || v != want {
+			t.Fatalf("%s = %d, %v", key, v, err)
+		}
+	}
+	if l.Len() != 2 {
+		t.Fatalf("len = %d", l.Len())
+	}
+}
+
+func TestLocalExpiresEntries(t *testing.T) {
+	l := NewLocal(10)
+	now := time.Unix(1000, 0)
+	l.now = func() time.Time { return now }
+	ctx := t.Context()
+	_ = l.Set(ctx, "k", "v", time.Second)
+
+	var v string
+	if err := l.Get(ctx, "k", &v); err != nil || v != "v" {
+		t.Fatalf("Get = %q, %v", v, err)
+	}
+	now = now.Add(time.Second)
+	if err := l.Get(ctx, "k", &v); !errors.Is(err, ErrMiss) {
+		t.Fatalf("Get after ttl: err = %v, want ErrMiss", err)
+	}
+	if l.Len() != 0 {
+		t.Fatalf("expired entry kept, len = %d", l.Len())
+	}
+}
+
+func TestLocalRejectsWrongType(t *testing.T) {
+	l := NewLocal(10)
+	_ = l.Set(t.Context(), "k", []string{"x"}, 0)
+	var v []int
+	if err := l.Get(t.Context(), "k", &v); err == nil {
+		t.Fatal("expected a type error")
+	}
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Locker elects a single holder of key across the processes sharing it.
+// TryLock does not wait: ok is false when someone else holds the lock. The
+// lock expires after ttl even if unlock is never called.
+type Locker interface {
+	TryLock(ctx context.Context, key string, ttl time.Duration) (unlock func(), ok bool, err error)
+}
+
+type RedisLocker struct {
+	client *redis.Client
+}
+
+func NewRedisLocker(client *redis.Client) *RedisLocker {
+	return &RedisLocker{client: client}
+}
+
+// releaseLock deletes the lock only if it still holds our token, so a holder
+// whose lock expired cannot release the next holder's.
+var releaseLock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+  return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func (l *RedisLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	token := hex.EncodeToString(b)
+	ok, err := l.client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return func() {
+		_ = releaseLock.Run(context.WithoutCancel(ctx), l.client, []string{key}, token).Err()
+	}, true, nil
+}
+
+// LocalLocker is a Locker for a single process.
+type LocalLocker struct {
+	mu    sync.Mutex
+	held  map[string]time.Time
+	token uint64
+	owner map[string]uint64
+}
+
+func NewLocalLocker() *LocalLocker {
+	return &LocalLocker{held: make(map[string]time.Time), owner: make(map[string]uint64)}
+}
+
+func (l *LocalLocker) TryLock(_ context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until, ok := l.held[key]; ok && time.Now().Before(until) {
+		return nil, false, nil
+	}
+	l.token++
+	token := l.token
+	l.held[key] = time.Now().Add(ttl)
+	l.owner[key] = token
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.owner[key] == token {
+			delete(l.held, key)
+			delete(l.owner, key)
+		}
+	}, true, nil
+}
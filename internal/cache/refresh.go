@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"RedColarTest/internal/lifecycle"
+	"RedColarTest/internal/logging"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Outcome tells how Fetch answered.
+type Outcome string
+
+const (
+	OutcomeHit   Outcome = "hit"
+	OutcomeStale Outcome = "stale"
+	OutcomeMiss  Outcome = "miss"
+	OutcomeError Outcome = "error"
+)
+
+type RefreshOptions struct {
+	// TTL is how long a loaded value is served as fresh.
+	TTL time.Duration
+	// StaleTTL is how much longer it is served while one caller refreshes it
+	// in the background, or while the source keeps failing. 0 disables
+	// serving stale values.
+	StaleTTL time.Duration
+	// Locker elects one process to load a missing or stale key; nil leaves
+	// each process to load on its own.
+	Locker Locker
+	// LoadTimeout bounds one load and the lock held for it. Defaults to 10s.
+	LoadTimeout time.Duration
+	// LockWait is how long a cold miss waits for the value another process
+	// is loading before loading it too. Defaults to 250ms.
+	LockWait time.Duration
+	Logger   *slog.Logger
+}
+
+// Refresher reads values through a Cache so that expiry does not turn into
+// a stampede on the source: concurrent misses in a process share one load,
+// processes take turns through the Locker, and values are refreshed in the
+// background while the stale copy keeps being served.
+type Refresher struct {
+	cache Cache
+	opts  RefreshOptions
+	log   *slog.Logger
+	now   func() time.Time
+
+	group      singleflight.Group
+	mu         sync.Mutex
+	inflight   map[string]struct{}
+	refreshing sync.WaitGroup
+}
+
+type entry[T any] struct {
+	Value     T         `json:"value"`
+	RefreshAt time.Time `json:"refresh_at"`
+}
+
+const lockPollInterval = 25 * time.Millisecond
+
+func NewRefresher(c Cache, opts RefreshOptions) *Refresher {
+	if opts.LoadTimeout <= 0 {
+		opts.LoadTimeout = 10 * time.Second
+	}
+	if opts.LockWait <= 0 {
+		opts.LockWait = 250 * time.Millisecond
+	}
+	return &Refresher{
+		cache:    c,
+		opts:     opts,
+		log:      logging.OrDiscard(opts.Logger),
+		now:      time.Now,
+		inflight: make(map[string]struct{}),
+	}
+}
+
+// Fetch returns the value cached under key, loading it on a miss. A value
+// past its TTL but within StaleTTL is returned as OutcomeStale and refreshed
+// in the background. Loads run detached from ctx cancellation, since other
+// callers may be waiting on them.
+func Fetch[T any](ctx context.Context, r *Refresher, key string, load func(context.Context) (T, error)) (T, Outcome, error) {
+	var e entry[T]
+	outcome := OutcomeMiss
+	err := r.cache.Get(ctx, key, &e)
+	switch {
+	case err == nil:
+		if r.now().Before(e.RefreshAt) {
+			return e.Value, OutcomeHit, nil
+		}
+		refresh(ctx, r, key, load)
+		return e.Value, OutcomeStale, nil
+	case errors.Is(err, ErrMiss):
+	default:
+		// Covers unreadable entries too; dropping the key lets the load
+		// below replace it.
+		r.log.WarnContext(ctx, "read cache failed", "key", key, "err", err)
+		outcome = OutcomeError
+		_ = r.cache.Delete(ctx, key)
+	}
+
+	v, err, _ := r.group.Do(key, func() (any, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.opts.LoadTimeout)
+		defer cancel()
+		return fill(loadCtx, r, key, load)
+	})
+	if err != nil {
+		var zero T
+		return zero, outcome, err
+	}
+	return v.(T), outcome, nil
+}
+
+// Wait blocks until background refreshes finish or ctx is done.
+func (r *Refresher) Wait(ctx context.Context) error {
+	return lifecycle.Wait(ctx, &r.refreshing)
+}
+
+func fill[T any](ctx context.Context, r *Refresher, key string, load func(context.Context) (T, error)) (T, error) {
+	if r.opts.Locker != nil {
+		unlock, ok, err := r.opts.Locker.TryLock(ctx, lockKey(key), r.opts.LoadTimeout)
+		switch {
+		case err != nil:
+			r.log.WarnContext(ctx, "take cache lock failed", "key", key, "err", err)
+		case ok:
+			defer unlock()
+		default:
+			if v, ok := await[T](ctx, r, key); ok {
+				return v, nil
+			}
+		}
+	}
+	v, err := load(ctx)
+	if err != nil {
+		return v, err
+	}
+	store(ctx, r, key, v)
+	return v, nil
+}
+
+// await polls for the value another process is loading.
+func await[T any](ctx context.Context, r *Refresher, key string) (T, bool) {
+	deadline := time.NewTimer(r.opts.LockWait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-deadline.C:
+			var zero T
+			return zero, false
+		case <-ticker.C:
+			var e entry[T]
+			if err := r.cache.Get(ctx, key, &e); err == nil {
+				return e.Value, true
+			}
+		}
+	}
+}
+
+// refresh reloads key in the background unless this process is already doing
+// so or another process holds the lock.
+func refresh[T any](ctx context.Context, r *Refresher, key string, load func(context.Context) (T, error)) {
+	r.mu.Lock()
+	if _, busy := r.inflight[key]; busy {
+		r.mu.Unlock()
+		return
+	}
+	r.inflight[key] = struct{}{}
+	r.refreshing.Add(1)
+	r.mu.Unlock()
+
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.inflight, key)
+			r.mu.Unlock()
+			r.refreshing.Done()
+		}()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.opts.LoadTimeout)
+		defer cancel()
+
+		if r.opts.Locker != nil {
+			unlock, ok, err := r.opts.Locker.TryLock(ctx, lockKey(key), r.opts.LoadTimeout)
+			if err != nil || !ok {
+				return
+			}
+			defer unlock()
+			// The previous holder may have just refreshed it.
+			var e entry[T]
+			if err := r.cache.Get(ctx, key, &e); err == nil && r.now().Before(e.RefreshAt) {
+				return
+			}
+		}
+		v, err := load(ctx)
+		if err != nil {
+			r.log.WarnContext(ctx, "refresh cache failed, serving stale value", "key", key, "err", err)
+			return
+		}
+		store(ctx, r, key, v)
+	}()
+}
+
+func store[T any](ctx context.Context, r *Refresher, key string, v T) {
+	e := entry[T]{Value: v, RefreshAt: r.now().Add(r.opts.TTL)}
+	if err := r.cache.Set(ctx, key, e, r.opts.TTL+r.opts.StaleTTL); err != nil {
+		r.log.WarnContext(ctx, "write cache failed", "key", key, "err", err)
+	}
+}
+
+func lockKey(key string) string {
+	return "lock:" + key
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowLoader counts loads and blocks each one until release is closed.
+type slowLoader struct {
+	calls   atomic.Int32
+	release chan struct{}
+	value   string
+	err     error
+}
+
+func (l *slowLoader) load(context.Context) (string, error) {
+	l.calls.Add(1)
+	<-l.release
+	return l.value, l.err
+}
+
+func TestFetchCoalescesConcurrentMisses(t *testing.T) {
+	r := NewRefresher(NewLocal(10), RefreshOptions{TTL: time.Minute})
+	l := &slowLoader{release: make(chan struct{}), value: "v1"}
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, _, err := Fetch(t.Context(), r, "k", l.load); err != nil || v != "v1" {
+				t.Errorf("Fetch = %q, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(l.release)
+	wg.Wait()
+
+	if n := l.calls.Load(); n != 1 {
+		t.Fatalf("loads = %d, want 1", n)
+	}
+	if _, outcome, _ := Fetch(t.Context(), r, "k", l.load); outcome != OutcomeHit {
+		t.Fatalf("outcome after load = %s, want hit", outcome)
+	}
+}
+
+func TestFetchServesStaleWhileRefreshing(t *testing.T) {
+	r := NewRefresher(NewLocal(10), RefreshOptions{TTL: time.Minute, StaleTTL: time.Hour})
+	ctx := t.Context()
+	first := &slowLoader{release: make(chan struct{}), value: "v1"}
+	close(first.release)
+	if _, _, err := Fetch(ctx, r, "k", first.load); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	r.now = func() time.Time { return start.Add(2 * time.Minute) }
+	second := &slowLoader{release: make(chan struct{}), value: "v2"}
+	for range 3 {
+		v, outcome, err := Fetch(ctx, r, "k", second.load)
+		if err != nil || v != "v1" || outcome != OutcomeStale {
+			t.Fatalf("Fetch past TTL = %q, %s, %v; want the stale value", v, outcome, err)
+		}
+	}
+	close(second.release)
+	if err := r.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := second.calls.Load(); n != 1 {
+		t.Fatalf("background refreshes = %d, want 1", n)
+	}
+	if v, outcome, _ := Fetch(ctx, r, "k", second.load); v != "v2" || outcome != OutcomeHit {
+		t.Fatalf("Fetch after refresh = %q, %s; want v2, hit", v, outcome)
+	}
+}
+
+func TestFetchKeepsStaleValueWhenRefreshFails(t *testing.T) {
+	r := NewRefresher(NewLocal(10), RefreshOptions{TTL: time.Minute, StaleTTL: time.Hour})
+	ctx := t.Context()
+	ok := &slowLoader{release: make(chan struct{}), value: "v1"}
+	close(ok.release)
+	if _, _, err := Fetch(ctx, r, "k", ok.load); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	r.now = func() time.Time { return start.Add(2 * time.Minute) }
+	failing := &slowLoader{release: make(chan struct{}), err: errors.New("db down")}
+	close(failing.release)
+	for range 2 {
+		if v, _, err := Fetch(ctx, r, "k", failing.load); err != nil || v != "v1" {
+			t.Fatalf("Fetch while the source fails = %q, %v", v, err)
+		}
+		if err := r.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFetchWaitsForAnotherProcessLoading(t *testing.T) {
+	shared := NewLocal(10)
+	locker := NewLocalLocker()
+	r := NewRefresher(shared, RefreshOptions{TTL: time.Minute, Locker: locker, LockWait: time.Second})
+	ctx := t.Context()
+
+	// Another process holds the lock and stores its value shortly.
+	unlock, ok, _ := locker.TryLock(ctx, lockKey("k"), time.Minute)
+	if !ok {
+		t.Fatal("lock not acquired")
+	}
+	other := NewRefresher(shared, RefreshOptions{TTL: time.Minute})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		store(ctx, other, "k", "theirs")
+		unlock()
+	}()
+
+	l := &slowLoader{release: make(chan struct{}), value: "ours"}
+	close(l.release)
+	v, _, err := Fetch(ctx, r, "k", l.load)
+	if err != nil || v != "theirs" {
+		t.Fatalf("Fetch = %q, %v; want the other process's value", v, err)
+	}
+	if n := l.calls.Load(); n != 0 {
+		t.Fatalf("loads = %d, want 0", n)
+	}
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+)
+
+// TwoTier serves reads from an in-process tier and falls back to a shared
+// remote tier, so a hot key is decoded once per process and local TTL rather
+// than on every request. Writes and deletes go to both tiers. Deletes do not
+// reach other replicas' local tiers; callers that need cross-replica
+// invalidation use versioned keys (VersionedKey) and the invalidation Bus,
+// so a bump makes every replica read a new key.
+type TwoTier struct {
+	local    *Local
+	remote   Cache
+	localTTL time.Duration
+}
+
+func NewTwoTier(local *Local, remote Cache, localTTL time.Duration) *TwoTier {
+	return &TwoTier{local: local, remote: remote, localTTL: localTTL}
+}
+
+func (t *TwoTier) Get(ctx context.Context, key string, dst any) error {
+	err := t.local.Get(ctx, key, dst)
+	if !errors.Is(err, ErrMiss) {
+		return err
+	}
+	if err := t.remote.Get(ctx, key, dst); err != nil {
+		return err
+	}
+	_ = t.local.Set(ctx, key, reflect.ValueOf(dst).Elem().Interface(), t.localTTL)
+	return nil
+}
+
+func (t *TwoTier) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	if err := t.remote.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	return t.local.Set(ctx, key, value, t.localFor(ttl))
+}
+
+func (t *TwoTier) Delete(ctx context.Context, keys ...string) error {
+	_ = t.local.Delete(ctx, keys...)
+	return t.remote.Delete(ctx, keys...)
+}
+
+func (t *TwoTier) Exists(ctx context.Context, key string) (bool, error) {
+	if ok, _ := t.local.Exists(ctx, key); ok {
+		return true, nil
+	}
+	return t.remote.Exists(ctx, key)
+}
+
+func (t *TwoTier) localFor(ttl time.Duration) time.Duration {
+	if t.localTTL > 0 && (ttl <= 0 || t.localTTL < ttl) {
+		return t.localTTL
+	}
+	return ttl
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingCache records remote reads.
+type countingCache struct {
+	Cache
+	gets int
+}
+
+func (c *countingCache) Get(ctx context.Context, key string, dst any) error {
+	c.gets++
+	return c.Cache.Get(ctx, key, dst)
+}
+
+func TestTwoTierServesRepeatedReadsLocally(t *testing.T) {
+	remote := &countingCache{Cache: NewLocal(10)}
+	writer := NewTwoTier(NewLocal(10), remote, time.Minute)
+	reader := NewTwoTier(NewLocal(10), remote, time.Minute)
+	ctx := t.Context()
+
+	if err := writer.Set(ctx, "k", []string{"a"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		var v []string
+		if err := reader.Get(ctx, "k", &v); err != nil || len(v) != 1 || v[0] != "a" {
+			t.Fatalf("Get = %v, %v", v, err)
+		}
+	}
+	if remote.gets != 1 {
+		t.Fatalf("remote reads = %d, want 1", remote.gets)
+	}
+}
+
+func TestTwoTierDelete(t *testing.T) {
+	remote := NewLocal(10)
+	c := NewTwoTier(NewLocal(10), remote, time.Minute)
+	ctx := t.Context()
+	_ = c.Set(ctx, "k", 1, time.Hour)
+	_ = c.Delete(ctx, "k")
+
+	var v int
+	if err := c.Get(ctx, "k", &v); !errors.Is(err, ErrMiss) {
+		t.Fatalf("err = %v, want ErrMiss", err)
+	}
+	if ok, _ := remote.Exists(ctx, "k"); ok {
+		t.Fatal("remote entry survived Delete")
+	}
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// Versions tracks a version per cache scope, e.g. one tenant's active
+// incidents. Entries are cached under VersionedKey, so bumping a scope makes
+// every older entry unreachable on all instances, whether or not deleting
+// them succeeded.
+type Versions interface {
+	Current(ctx context.Context, scope string) (int64, error)
+	Bump(ctx context.Context, scope string) (int64, error)
+}
+
+// VersionedKey is the cache key of scope at version.
+func VersionedKey(scope string, version int64) string {
+	return "cache:" + scope + ":v" + strconv.FormatInt(version, 10)
+}
+
+// LocalVersions keeps versions in process memory. It is enough when a
+// single instance does all writes, as with the memory backend.
+type LocalVersions struct {
+	mu       sync.Mutex
+	versions map[string]int64
+}
+
+func NewLocalVersions() *LocalVersions {
+	return &LocalVersions{versions: make(map[string]int64)}
+}
+
+func (v *LocalVersions) Current(_ context.Context, scope string) (int64, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.versions[scope], nil
+}
+
+func (v *LocalVersions) Bump(_ context.Context, scope string) (int64, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.versions[scope]++
+	return v.versions[scope], nil
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"testing"
+)
+
+func TestLocalVersionsBumpPerScope(t *testing.T) {
+	v := NewLocalVersions()
+	ctx := t.Context()
+
+	if cur, _ := v.Current(ctx, "a"); cur != 0 {
+		t.Fatalf("initial version = %d, want 0", cur)
+	}
+	v.Bump(ctx, "a")
+	if next, _ := v.Bump(ctx, "a"); next != 2 {
+		t.Fatalf("version after two bumps = %d, want 2", next)
+	}
+	if cur, _ := v.Current(ctx, "b"); cur != 0 {
+		t.Fatalf("other scope version = %d, want 0", cur)
+	}
+	if VersionedKey("a", 2) == VersionedKey("a", 1) {
+		t.Fatal("versions share a key")
+	}
+}
+
+func TestBusIgnoresOlderVersions(t *testing.T) {
+	b := NewBus(nil, BusOptions{})
+
+	b.observe("a", 3, "")
+	b.observe("a", 2, "")
+	if got := b.known("a"); got != 3 {
+		t.Fatalf("version after a late message = %d, want 3", got)
+	}
+	b.observe("a", 5, "")
+	if got := b.known("a"); got != 5 {
+		t.Fatalf("version after a newer message = %d, want 5", got)
+	}
+}
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Client is a registered app that signs its location checks with Secret.
+type Client struct {
+	ID        int64      `json:"id"`
+	TenantID  int64      `json:"tenant_id"`
+	ClientID  string     `json:"client_id"`
+	Name      string     `json:"name"`
+	Secret    string     `json:"-"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// AuthMode controls whether location checks must be signed by a registered
+// client.
+type AuthMode string
+
+const (
+	// AuthOff ignores client headers.
+	AuthOff AuthMode = "off"
+	// AuthOptional verifies requests that carry X-Client-Id and lets
+	// unsigned ones through.
+	AuthOptional AuthMode = "optional"
+	// AuthRequired rejects unsigned requests.
+	AuthRequired AuthMode = "required"
+)
+
+func (m AuthMode) Valid() bool {
+	switch m {
+	case AuthOff, AuthOptional, AuthRequired:
+		return true
+	}
+	return false
+}
+
+type ctxKey struct{}
+
+func WithClientID(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, clientID)
+}
+
+// ClientID returns the client that signed the request, or "" for unsigned
+// requests.
+func ClientID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
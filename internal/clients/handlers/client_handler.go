@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"RedColarTest/internal/clients/services"
+	"RedColarTest/internal/common"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ClientHandler struct {
+	svc *services.ClientService
+}
+
+func NewClientHandler(svc *services.ClientService) *ClientHandler {
+	return &ClientHandler{svc: svc}
+}
+
+type registerClientRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+func (h *ClientHandler) Register(c *gin.Context) {
+	var req registerClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	out, secret, err := h.svc.Register(c.Request.Context(), req.Name)
+	if err != nil {
+		if err.Code == common.CodeNotValid {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		if err.Code == common.CodeUnavailable {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"client": out, "secret": secret})
+}
+
+func (h *ClientHandler) List(c *gin.Context) {
+	items, err := h.svc.List(c.Request.Context())
+	if err != nil {
+		if err.Code == common.CodeUnavailable {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func (h *ClientHandler) Revoke(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	out, errorDto := h.svc.Revoke(c.Request.Context(), id)
+	if errorDto != nil {
+		if errorDto.Code == common.CodeNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		if errorDto.Code == common.CodeUnavailable {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": errorDto.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errorDto.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
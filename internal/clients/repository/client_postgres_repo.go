@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"RedColarTest/internal/clients/domain"
+	"RedColarTest/internal/common"
+	"RedColarTest/internal/logging"
+	tenant "RedColarTest/internal/tenants/domain"
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const clientColumns = `id, tenant_id, client_id, name, secret, revoked_at, created_at`
+
+type ClientRepo struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewClientRepo(db *pgxpool.Pool, logger *slog.Logger) *ClientRepo {
+	return &ClientRepo{db: db, log: logging.OrDiscard(logger)}
+}
+
+func (r *ClientRepo) Create(ctx context.Context, in domain.Client) (domain.Client, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return domain.Client{}, cerr
+	}
+	const q = `
+insert into clients (tenant_id, client_id, name, secret)
+values ($1, $2, $3, $4)
+returning ` + clientColumns + `;
+`
+	out, err := scanClient(r.db.QueryRow(ctx, q, tenantID, in.ClientID, in.Name, in.Secret))
+	if err != nil {
+		return domain.Client{}, common.Internal(ctx, r.log, "ClientRepo.Create", err)
+	}
+	return out, nil
+}
+
+// GetByClientID looks a client up across tenants; it is used to authenticate
+// requests before the tenant is known.
+func (r *ClientRepo) GetByClientID(ctx context.Context, clientID string) (domain.Client, *common.Error) {
+	const q = `select ` + clientColumns + ` from clients where client_id = $1;`
+	out, err := scanClient(r.db.QueryRow(ctx, q, clientID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Client{}, common.NewError(common.CodeNotFound, err.Error())
+	}
+	if err != nil {
+		return domain.Client{}, common.Internal(ctx, r.log, "ClientRepo.GetByClientID", err)
+	}
+	return out, nil
+}
+
+func (r *ClientRepo) List(ctx context.Context) ([]domain.Client, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return nil, cerr
+	}
+	const q = `select ` + clientColumns + ` from clients where tenant_id = $1 order by id desc;`
+	rows, err := r.db.Query(ctx, q, tenantID)
+	if err != nil {
+		return nil, common.Internal(ctx, r.log, "ClientRepo.List", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.Client, 0)
+	for rows.Next() {
+		it, err := scanClient(rows)
+		if err != nil {
+			return nil, common.Internal(ctx, r.log, "ClientRepo.List", err)
+		}
+		items = append(items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, common.Internal(ctx, r.log, "ClientRepo.List", err)
+	}
+	return items, nil
+}
+
+func (r *ClientRepo) Revoke(ctx context.Context, id int64) (domain.Client, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return domain.Client{}, cerr
+	}
+	const q = `
+update clients
+set revoked_at = now()
+where id = $1 and tenant_id = $2 and revoked_at is null
+returning ` + clientColumns + `;
+`
+	out, err := scanClient(r.db.QueryRow(ctx, q, id, tenantID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Client{}, common.NewError(common.CodeNotFound, err.Error())
+	}
+	if err != nil {
+		return domain.Client{}, common.Internal(ctx, r.log, "ClientRepo.Revoke", err)
+	}
+	return out, nil
+}
+
+func scanClient(row pgx.Row) (domain.Client, error) {
+	var out domain.Client
+	err := row.Scan(
+		&out.ID,
+		&out.TenantID,
+		&out.ClientID,
+		&out.Name,
+		&out.Secret,
+		&out.RevokedAt,
+		&out.CreatedAt,
+	)
+	return out, err
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"RedColarTest/internal/clients/domain"
+	"RedColarTest/internal/clients/repository"
+	"RedColarTest/internal/common"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const lookupCacheTTL = time.Minute
+
+var clientIDPattern = regexp.MustCompile(`^app_[0-9a-f]{16}$`)
+
+type cachedClient struct {
+	client    domain.Client
+	expiresAt time.Time
+}
+
+type ClientService struct {
+	repo *repository.ClientRepo
+
+	mu    sync.Mutex
+	cache map[string]cachedClient
+}
+
+func NewClientService(repo *repository.ClientRepo) *ClientService {
+	return &ClientService{repo: repo, cache: make(map[string]cachedClient)}
+}
+
+// Register creates a client for the context tenant and returns it together
+// with the signing secret, which is only ever shown once.
+func (s *ClientService) Register(ctx context.Context, name string) (domain.Client, string, *common.Error) {
+	if name == "" {
+		return domain.Client{}, "", common.NewError(common.CodeNotValid, "name is required")
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return domain.Client{}, "", common.NewError(common.CodeIternalErr, err.Error())
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return domain.Client{}, "", common.NewError(common.CodeIternalErr, err.Error())
+	}
+	out, repoErr := s.repo.Create(ctx, domain.Client{ClientID: "app_" + id, Name: name, Secret: secret})
+	if repoErr != nil {
+		return domain.Client{}, "", repoErr
+	}
+	return out, secret, nil
+}
+
+func (s *ClientService) List(ctx context.Context) ([]domain.Client, *common.Error) {
+	return s.repo.List(ctx)
+}
+
+func (s *ClientService) Revoke(ctx context.Context, id int64) (domain.Client, *common.Error) {
+	if id <= 0 {
+		return domain.Client{}, common.NewError(common.CodeNotValid, fmt.Sprintf("Client with id %d not found", id))
+	}
+	out, err := s.repo.Revoke(ctx, id)
+	if err == nil {
+		s.mu.Lock()
+		delete(s.cache, out.ClientID)
+		s.mu.Unlock()
+	}
+	return out, err
+}
+
+// LookupClient implements middleware.ClientStore. Registered clients are
+// cached for a minute; a revocation on another instance takes effect within
+// that time. Unknown ids are not cached so the cache stays bounded by the
+// number of clients.
+func (s *ClientService) LookupClient(ctx context.Context, clientID string) (string, int64, bool) {
+	if !clientIDPattern.MatchString(clientID) {
+		return "", 0, false
+	}
+	now := time.Now()
+	s.mu.Lock()
+	cached, ok := s.cache[clientID]
+	s.mu.Unlock()
+	if !ok || !now.Before(cached.expiresAt) {
+		client, err := s.repo.GetByClientID(ctx, clientID)
+		if err != nil {
+			return "", 0, false
+		}
+		cached = cachedClient{client: client, expiresAt: now.Add(lookupCacheTTL)}
+		s.mu.Lock()
+		s.cache[clientID] = cached
+		s.mu.Unlock()
+	}
+	if cached.client.RevokedAt != nil {
+		return "", 0, false
+	}
+	return cached.client.Secret, cached.client.TenantID, true
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
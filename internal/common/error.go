@@ -1,11 +1,24 @@
 package common
 
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
 type ErrCode string
 
 const (
-	CodeNotFound   ErrCode = "NOT_FOUND"
-	CodeNotValid   ErrCode = "NOT_VALID"
-	CodeIternalErr ErrCode = "INTERNAL_ERROR"
+	CodeNotFound    ErrCode = "NOT_FOUND"
+	CodeNotValid    ErrCode = "NOT_VALID"
+	CodeIternalErr  ErrCode = "INTERNAL_ERROR"
+	CodeUnavailable ErrCode = "UNAVAILABLE"
 )
 
 type Error struct {
@@ -20,3 +33,34 @@ func NewError(code ErrCode, text string) *Error {
 func (e *Error) Error() string {
 	return e.Text
 }
+
+// Internal logs err with the operation that failed and wraps it. Errors from
+// not reaching the database in time are CodeUnavailable so callers can
+// degrade or retry; everything else is an internal error.
+func Internal(ctx context.Context, logger *slog.Logger, op string, err error) *Error {
+	logger.ErrorContext(ctx, "operation failed", "op", op, "err", err)
+	if unreachable(err) {
+		return NewError(CodeUnavailable, err.Error())
+	}
+	return NewError(CodeIternalErr, err.Error())
+}
+
+// unreachable reports connection failures, dropped connections and timeouts,
+// including Postgres connection exceptions (class 08) and shutdowns
+// (57P01-57P03).
+func unreachable(err error) bool {
+	var (
+		connectErr *pgconn.ConnectError
+		netErr     net.Error
+		pgErr      *pgconn.PgError
+	)
+	switch {
+	case errors.As(err, &connectErr), errors.As(err, &netErr):
+		return true
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, io.ErrUnexpectedEOF), pgconn.Timeout(err):
+		return true
+	case errors.As(err, &pgErr):
+		return strings.HasPrefix(pgErr.Code, "08") || slices.Contains([]string{"57P01", "57P02", "57P03"}, pgErr.Code)
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestInternalMarksUnreachableDatabase(t *testing.T) {
+	log := slog.New(slog.DiscardHandler)
+	cases := []struct {
+		err  error
+		want ErrCode
+	}{
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, CodeUnavailable},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), CodeUnavailable},
+		{&pgconn.PgError{Code: "57P01"}, CodeUnavailable},
+		{&pgconn.PgError{Code: "08006"}, CodeUnavailable},
+		{&pgconn.PgError{Code: "23505"}, CodeIternalErr},
+		{errors.New("scan failed"), CodeIternalErr},
+	}
+	for _, tc := range cases {
+		if got := Internal(t.Context(), log, "op", tc.err).Code; got != tc.want {
+			t.Errorf("Internal(%v).Code = %s, want %s", tc.err, got, tc.want)
+		}
+	}
+}
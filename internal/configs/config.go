@@ -0,0 +1,298 @@
+package configs
+
+import (
+	clients "RedColarTest/internal/clients/domain"
+	"RedColarTest/internal/ratelimit"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	HTTP        HTTP
+	Database    Database
+	Redis       Redis
+	Log         Log
+	Tracing     Tracing
+	Auth        Auth
+	RateLimits  RateLimits
+	Idempotency Idempotency
+	Cache       Cache
+	Stats       Stats
+	Incidents   Incidents
+	Degraded    Degraded
+	Webhook     Webhook
+	Health      Health
+}
+
+type HTTP struct {
+	Addr string
+	// MetricsAddr is a separate listener for /metrics so it is not exposed
+	// with the public API; empty disables it.
+	MetricsAddr     string
+	TrustedProxies  []string
+	ShutdownTimeout time.Duration
+	// ShutdownDelay keeps serving after /readyz starts failing so load
+	// balancers can take the instance out before the listener closes.
+	ShutdownDelay time.Duration
+}
+
+type Database struct {
+	URL string
+	// AutoMigrate applies pending migrations before the server starts.
+	AutoMigrate    bool
+	MigrateTimeout time.Duration
+}
+
+type Redis struct {
+	Addr     string
+	Password string
+	DB       int
+}
+
+type Log struct {
+	Level  string
+	Format string
+}
+
+type Tracing struct {
+	Exporter    string
+	ServiceName string
+	SampleRatio float64
+}
+
+type Auth struct {
+	OperatorAPIKey    string
+	ClientAuthMode    clients.AuthMode
+	ClientAuthMaxSkew time.Duration
+	JWT               JWT
+}
+
+type JWT struct {
+	// JWKS is a URL or a file path; JWT auth is off when empty.
+	JWKS        string
+	JWKSRefresh time.Duration
+	Issuer      string
+	Audience    string
+	RolesClaim  string
+	RoleMapping map[string]string
+	TenantClaim string
+}
+
+type RateLimits struct {
+	LocationCheckIP   ratelimit.Limit
+	LocationCheckUser ratelimit.Limit
+	Operator          ratelimit.Limit
+}
+
+type Idempotency struct {
+	TTL time.Duration
+}
+
+type Cache struct {
+	IncidentsTTL time.Duration
+	// Backend is redis, memory or two_tier.
+	Backend         string
+	LocalMaxEntries int
+	LocalTTL        time.Duration
+	// StaleTTL is how long past IncidentsTTL a cached value is still served
+	// while it is refreshed in the background.
+	StaleTTL time.Duration
+	// VersionCheck is how often known cache versions are re-read to recover
+	// from missed invalidation messages.
+	VersionCheck time.Duration
+}
+
+type Stats struct {
+	WindowMinutes int
+}
+
+type Incidents struct {
+	ExpiryCheckInterval time.Duration
+}
+
+type Degraded struct {
+	// SnapshotPath keeps the last known good incident snapshots on disk;
+	// empty keeps them in memory only.
+	SnapshotPath string
+	// SnapshotMaxAge is the oldest snapshot checks are served from; 0
+	// disables serving from snapshots.
+	SnapshotMaxAge time.Duration
+	// SpoolMaxLen bounds the spool of checks that could not be saved; 0
+	// disables spooling.
+	SpoolMaxLen int
+	SpoolReplay time.Duration
+}
+
+type Webhook struct {
+	URL                    string
+	Secret                 string
+	MaxRetries             int
+	RetryBase              time.Duration
+	RetryMax               time.Duration
+	Timeout                time.Duration
+	Workers                int
+	DestinationConcurrency int
+	BreakerThreshold       int
+	BreakerCooldown        time.Duration
+	BatchWindow            time.Duration
+	BatchMaxSize           int
+	DigestCooldown         time.Duration
+}
+
+type Health struct {
+	CheckTimeout time.Duration
+	// NonCritical names checks that only degrade readiness.
+	NonCritical     []string
+	HeartbeatMaxAge time.Duration
+}
+
+func Default() Config {
+	return Config{
+		HTTP: HTTP{
+			Addr:            ":8080",
+			MetricsAddr:     ":9090",
+			ShutdownTimeout: 25 * time.Second,
+		},
+		Database: Database{MigrateTimeout: 5 * time.Minute},
+		Redis:    Redis{Addr: "localhost:6379"},
+		Log:      Log{Level: "info", Format: "json"},
+		Tracing: Tracing{
+			Exporter:    "none",
+			ServiceName: "incident-api",
+			SampleRatio: 1,
+		},
+		Auth: Auth{
+			ClientAuthMode:    clients.AuthOff,
+			ClientAuthMaxSkew: 5 * time.Minute,
+			JWT: JWT{
+				JWKSRefresh: 5 * time.Minute,
+				RolesClaim:  "roles",
+				RoleMapping: map[string]string{},
+				TenantClaim: "tenant",
+			},
+		},
+		RateLimits: RateLimits{
+			LocationCheckIP:   ratelimit.Limit{Limit: 60, Window: time.Minute},
+			LocationCheckUser: ratelimit.Limit{Limit: 30, Window: time.Minute},
+			Operator:          ratelimit.Limit{Limit: 600, Window: time.Minute},
+		},
+		Idempotency: Idempotency{TTL: 24 * time.Hour},
+		Cache: Cache{
+			IncidentsTTL:    time.Minute,
+			Backend:         "redis",
+			LocalMaxEntries: 1000,
+			LocalTTL:        5 * time.Second,
+			StaleTTL:        5 * time.Minute,
+			VersionCheck:    10 * time.Second,
+		},
+		Stats:     Stats{WindowMinutes: 60},
+		Incidents: Incidents{ExpiryCheckInterval: 30 * time.Second},
+		Degraded: Degraded{
+			SnapshotMaxAge: time.Hour,
+			SpoolMaxLen:    100000,
+			SpoolReplay:    15 * time.Second,
+		},
+		Webhook: Webhook{
+			MaxRetries:             5,
+			RetryBase:              10 * time.Second,
+			RetryMax:               10 * time.Minute,
+			Timeout:                5 * time.Second,
+			Workers:                4,
+			DestinationConcurrency: 2,
+			BreakerThreshold:       5,
+			BreakerCooldown:        30 * time.Second,
+		},
+		Health: Health{
+			CheckTimeout:    time.Second,
+			HeartbeatMaxAge: 30 * time.Second,
+		},
+	}
+}
+
+// HealthChecks are the readiness checks that can be marked non-critical.
+var HealthChecks = []string{"postgres", "redis", "webhook_queue", "cache"}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+		}
+	}
+	nonNegative := func(key string, d time.Duration) {
+		check(d >= 0, key, "must not be negative")
+	}
+
+	check(c.HTTP.Addr != "", "http.addr", "is required")
+	check(c.HTTP.MetricsAddr != c.HTTP.Addr, "http.metrics_addr", "must differ from http.addr")
+	check(c.HTTP.ShutdownTimeout > 0, "http.shutdown_timeout_seconds", "must be > 0")
+	nonNegative("http.shutdown_delay_seconds", c.HTTP.ShutdownDelay)
+	check(c.HTTP.ShutdownDelay < c.HTTP.ShutdownTimeout, "http.shutdown_delay_seconds", "must be less than http.shutdown_timeout_seconds")
+
+	check(c.Database.URL != "", "database.url", "is required (DATABASE_URL)")
+	check(c.Database.MigrateTimeout > 0, "database.migrate_timeout_seconds", "must be > 0")
+	check(c.Redis.Addr != "", "redis.addr", "is required")
+	check(c.Redis.DB >= 0, "redis.db", "must not be negative")
+
+	check(slices.Contains([]string{"debug", "info", "warn", "error"}, strings.ToLower(c.Log.Level)),
+		"log.level", "%q: expected debug, info, warn or error", c.Log.Level)
+	check(slices.Contains([]string{"json", "text"}, strings.ToLower(c.Log.Format)),
+		"log.format", "%q: expected json or text", c.Log.Format)
+
+	check(slices.Contains([]string{"none", "stdout", "otlp"}, strings.ToLower(c.Tracing.Exporter)),
+		"tracing.exporter", "%q: expected otlp, stdout or none", c.Tracing.Exporter)
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1")
+
+	check(c.Auth.ClientAuthMode.Valid(), "auth.client_auth_mode", "%q: expected off, optional or required", c.Auth.ClientAuthMode)
+	check(c.Auth.ClientAuthMaxSkew > 0, "auth.client_auth_max_skew_seconds", "must be > 0")
+	if c.Auth.JWT.JWKS != "" {
+		check(c.Auth.JWT.JWKSRefresh > 0, "auth.jwt.jwks_refresh_seconds", "must be > 0")
+		check(c.Auth.JWT.RolesClaim != "", "auth.jwt.roles_claim", "is required when JWT is enabled")
+	}
+
+	nonNegative("idempotency.ttl_seconds", c.Idempotency.TTL)
+	nonNegative("cache.incidents_ttl_seconds", c.Cache.IncidentsTTL)
+	nonNegative("cache.stale_ttl_seconds", c.Cache.StaleTTL)
+	check(slices.Contains([]string{"redis", "memory", "two_tier"}, c.Cache.Backend),
+		"cache.backend", "%q: expected redis, memory or two_tier", c.Cache.Backend)
+	check(c.Cache.LocalMaxEntries > 0, "cache.local_max_entries", "must be > 0")
+	check(c.Cache.LocalTTL > 0, "cache.local_ttl_seconds", "must be > 0")
+	check(c.Cache.VersionCheck > 0, "cache.version_check_seconds", "must be > 0")
+	check(c.Stats.WindowMinutes > 0, "stats.window_minutes", "must be > 0")
+	nonNegative("incidents.expiry_check_seconds", c.Incidents.ExpiryCheckInterval)
+	nonNegative("degraded.snapshot_max_age_seconds", c.Degraded.SnapshotMaxAge)
+	check(c.Degraded.SpoolMaxLen >= 0, "degraded.spool_max_len", "must be >= 0")
+	check(c.Degraded.SpoolReplay > 0, "degraded.spool_replay_seconds", "must be > 0")
+
+	w := c.Webhook
+	if w.URL != "" {
+		u, err := url.Parse(w.URL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"webhook.url", "%q: expected an absolute http(s) URL", w.URL)
+	}
+	check(w.MaxRetries >= 0, "webhook.max_retries", "must not be negative")
+	nonNegative("webhook.retry_base_seconds", w.RetryBase)
+	nonNegative("webhook.retry_max_seconds", w.RetryMax)
+	check(w.RetryMax == 0 || w.RetryMax >= w.RetryBase, "webhook.retry_max_seconds", "must be >= webhook.retry_base_seconds")
+	check(w.Timeout > 0, "webhook.timeout_seconds", "must be > 0")
+	check(w.Workers >= 1, "webhook.workers", "must be >= 1")
+	check(w.DestinationConcurrency >= 1, "webhook.destination_concurrency", "must be >= 1")
+	check(w.BreakerThreshold >= 0, "webhook.breaker_threshold", "must not be negative")
+	nonNegative("webhook.breaker_cooldown_seconds", w.BreakerCooldown)
+	nonNegative("webhook.batch_window_ms", w.BatchWindow)
+	check(w.BatchMaxSize >= 0, "webhook.batch_max_size", "must not be negative")
+	nonNegative("webhook.digest_cooldown_seconds", w.DigestCooldown)
+
+	check(c.Health.CheckTimeout > 0, "health.check_timeout_ms", "must be > 0")
+	nonNegative("health.heartbeat_max_age_seconds", c.Health.HeartbeatMaxAge)
+	for _, name := range c.Health.NonCritical {
+		check(slices.Contains(HealthChecks, name), "health.non_critical", "unknown check %q: expected one of %s", name, strings.Join(HealthChecks, ", "))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,235 @@
+package configs
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the config file when -config is not given.
+const FileEnv = "CONFIG_FILE"
+
+const redacted = "<redacted>"
+
+// Load builds the configuration with increasing precedence: defaults, the
+// YAML or TOML file from -config or CONFIG_FILE, environment variables, then
+// flags. Every setting gets a flag named after its file key, e.g.
+// -webhook.workers=8, registered on fs next to the caller's own flags. Parse
+// and validation errors are collected and returned together.
+func Load(fs *flag.FlagSet, args []string) (Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	configFile := fs.String("config", "", "path to a YAML or TOML config file (env "+FileEnv+")")
+	flags := make(map[string]*rawFlag, len(settings))
+	for _, s := range settings {
+		f := &rawFlag{def: s.value.String()}
+		if b, ok := s.value.(interface{ IsBoolFlag() bool }); ok {
+			f.boolean = b.IsBoolFlag()
+		}
+		if s.secret {
+			f.def = ""
+		}
+		flags[s.key] = f
+		fs.Var(f, s.key, s.usage+" (env "+s.env[0]+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	var errs []error
+	path := *configFile
+	if path == "" {
+		path = os.Getenv(FileEnv)
+	}
+	if path != "" {
+		errs = append(errs, loadFile(path, settings)...)
+	}
+
+	for _, s := range settings {
+		for _, name := range s.env {
+			raw, ok := os.LookupEnv(name)
+			if !ok || raw == "" {
+				continue
+			}
+			if err := s.value.Set(raw); err != nil {
+				errs = append(errs, fmt.Errorf("env %s: %w", name, err))
+			}
+			break
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		raw, ok := f.Value.(*rawFlag)
+		if !ok {
+			return
+		}
+		for _, s := range settings {
+			if s.key == f.Name {
+				if err := s.value.Set(raw.val); err != nil {
+					errs = append(errs, fmt.Errorf("flag -%s: %w", f.Name, err))
+				}
+			}
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return cfg, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return cfg, nil
+}
+
+func loadFile(path string, settings []setting) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{fmt.Errorf("config file: %w", err)}
+	}
+	var tree map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return []error{fmt.Errorf("config file %s: expected .yaml, .yml or .toml", path)}
+	}
+	if err != nil {
+		return []error{fmt.Errorf("config file %s: %w", path, err)}
+	}
+
+	byKey := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+	var errs []error
+	var walk func(prefix string, node map[string]any)
+	walk = func(prefix string, node map[string]any) {
+		for name, v := range node {
+			key := prefix + name
+			if s, ok := byKey[key]; ok {
+				if err := s.value.Set(scalar(v)); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %s: %w", path, key, err))
+				}
+				continue
+			}
+			if child, ok := v.(map[string]any); ok {
+				walk(key+".", child)
+				continue
+			}
+			errs = append(errs, fmt.Errorf("%s: unknown key %s", path, key))
+		}
+	}
+	walk("", tree)
+	return errs
+}
+
+// scalar renders a file value the way it would be written in the
+// environment: lists are comma-joined and maps become k=v pairs.
+func scalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, it := range v {
+			parts = append(parts, scalar(it))
+		}
+		return strings.Join(parts, ",")
+	case map[string]any:
+		pairs := make([]string, 0, len(v))
+		for k, it := range v {
+			pairs = append(pairs, k+"="+scalar(it))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Print writes the effective configuration as YAML in the file layout, with
+// secrets redacted, so the output can be used as a config file template.
+func (c Config) Print(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, s := range c.settings() {
+		node := root
+		parts := strings.Split(s.key, ".")
+		for _, part := range parts[:len(parts)-1] {
+			node = child(node, part)
+		}
+		val := s.value.String()
+		if s.secret && val != "" {
+			val = redact(val)
+		}
+		valNode := &yaml.Node{Kind: yaml.ScalarNode, Value: val}
+		if val == "" {
+			valNode.Style = yaml.DoubleQuotedStyle
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: parts[len(parts)-1]}, valNode)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func child(node *yaml.Node, name string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return node.Content[i+1]
+		}
+	}
+	next := &yaml.Node{Kind: yaml.MappingNode}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, next)
+	return next
+}
+
+// redact keeps the non-secret part of connection URLs.
+func redact(val string) string {
+	if u, err := url.Parse(val); err == nil && u.Scheme != "" && u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			return u.Redacted()
+		}
+	}
+	return redacted
+}
+
+// rawFlag keeps the flag text so flags can be applied after the file and the
+// environment.
+type rawFlag struct {
+	val     string
+	def     string
+	boolean bool
+}
+
+// IsBoolFlag lets boolean settings be passed as a bare -key.
+func (f *rawFlag) IsBoolFlag() bool { return f.boolean }
+
+func (f *rawFlag) Set(raw string) error {
+	f.val = raw
+	return nil
+}
+
+func (f *rawFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	if f.val != "" {
+		return f.val
+	}
+	return f.def
+}
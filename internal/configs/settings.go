@@ -0,0 +1,255 @@
+package configs
+
+import (
+	clients "RedColarTest/internal/clients/domain"
+	"RedColarTest/internal/ratelimit"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setting binds one config field to its file key (also the flag name) and
+// environment variables. The first env name is the canonical one.
+type setting struct {
+	key    string
+	env    []string
+	usage  string
+	secret bool
+	value  value
+}
+
+type value interface {
+	Set(raw string) error
+	String() string
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{key: "http.addr", env: []string{"HTTP_ADDR"}, usage: "listen address", value: stringValue{&c.HTTP.Addr}},
+		{key: "http.metrics_addr", env: []string{"METRICS_ADDR"}, usage: "listen address for /metrics (disabled when empty)", value: stringValue{&c.HTTP.MetricsAddr}},
+		{key: "http.trusted_proxies", env: []string{"TRUSTED_PROXIES"}, usage: "comma-separated proxies trusted for X-Forwarded-For", value: listValue{&c.HTTP.TrustedProxies}},
+		{key: "http.shutdown_timeout_seconds", env: []string{"SHUTDOWN_TIMEOUT_SECONDS"}, usage: "time allowed for graceful shutdown", value: durationValue{&c.HTTP.ShutdownTimeout, time.Second}},
+		{key: "http.shutdown_delay_seconds", env: []string{"SHUTDOWN_DELAY_SECONDS"}, usage: "time /readyz reports down before the server stops accepting requests", value: durationValue{&c.HTTP.ShutdownDelay, time.Second}},
+
+		{key: "database.url", env: []string{"DATABASE_URL", "database_url"}, usage: "Postgres connection string", secret: true, value: stringValue{&c.Database.URL}},
+		{key: "database.auto_migrate", env: []string{"DATABASE_AUTO_MIGRATE"}, usage: "apply pending migrations on start", value: boolValue{&c.Database.AutoMigrate}},
+		{key: "database.migrate_timeout_seconds", env: []string{"DATABASE_MIGRATE_TIMEOUT_SECONDS"}, usage: "time allowed to wait for the migration lock and migrate", value: durationValue{&c.Database.MigrateTimeout, time.Second}},
+
+		{key: "redis.addr", env: []string{"REDIS_ADDR"}, usage: "Redis address", value: stringValue{&c.Redis.Addr}},
+		{key: "redis.password", env: []string{"REDIS_PASSWORD"}, usage: "Redis password", secret: true, value: stringValue{&c.Redis.Password}},
+		{key: "redis.db", env: []string{"REDIS_DB"}, usage: "Redis database", value: intValue{&c.Redis.DB}},
+
+		{key: "log.level", env: []string{"LOG_LEVEL"}, usage: "debug, info, warn or error", value: stringValue{&c.Log.Level}},
+		{key: "log.format", env: []string{"LOG_FORMAT"}, usage: "json or text", value: stringValue{&c.Log.Format}},
+
+		{key: "tracing.exporter", env: []string{"OTEL_TRACES_EXPORTER"}, usage: "otlp, stdout or none", value: stringValue{&c.Tracing.Exporter}},
+		{key: "tracing.service_name", env: []string{"OTEL_SERVICE_NAME"}, usage: "service name in traces", value: stringValue{&c.Tracing.ServiceName}},
+		{key: "tracing.sample_ratio", env: []string{"OTEL_TRACES_SAMPLE_RATIO"}, usage: "share of new traces recorded, 0..1", value: floatValue{&c.Tracing.SampleRatio}},
+
+		{key: "auth.operator_api_key", env: []string{"OPERATOR_API_KEY", "operator_api_key"}, usage: "static operator key with all permissions", secret: true, value: stringValue{&c.Auth.OperatorAPIKey}},
+		{key: "auth.client_auth_mode", env: []string{"CLIENT_AUTH_MODE"}, usage: "off, optional or required", value: clientAuthModeValue{&c.Auth.ClientAuthMode}},
+		{key: "auth.client_auth_max_skew_seconds", env: []string{"CLIENT_AUTH_MAX_SKEW_SECONDS"}, usage: "allowed client clock skew", value: durationValue{&c.Auth.ClientAuthMaxSkew, time.Second}},
+		{key: "auth.jwt.jwks", env: []string{"JWT_JWKS"}, usage: "JWKS URL or file; enables JWT auth", value: stringValue{&c.Auth.JWT.JWKS}},
+		{key: "auth.jwt.jwks_refresh_seconds", env: []string{"JWT_JWKS_REFRESH_SECONDS"}, usage: "JWKS refresh interval", value: durationValue{&c.Auth.JWT.JWKSRefresh, time.Second}},
+		{key: "auth.jwt.issuer", env: []string{"JWT_ISSUER"}, usage: "expected iss", value: stringValue{&c.Auth.JWT.Issuer}},
+		{key: "auth.jwt.audience", env: []string{"JWT_AUDIENCE"}, usage: "expected aud", value: stringValue{&c.Auth.JWT.Audience}},
+		{key: "auth.jwt.roles_claim", env: []string{"JWT_ROLES_CLAIM"}, usage: "claim with roles", value: stringValue{&c.Auth.JWT.RolesClaim}},
+		{key: "auth.jwt.role_mapping", env: []string{"JWT_ROLE_MAPPING"}, usage: "idp=local role pairs, comma-separated", value: mapValue{&c.Auth.JWT.RoleMapping}},
+		{key: "auth.jwt.tenant_claim", env: []string{"JWT_TENANT_CLAIM"}, usage: "claim with the tenant", value: stringValue{&c.Auth.JWT.TenantClaim}},
+
+		{key: "rate_limits.location_check_ip", env: []string{"RATE_LIMIT_LOCATION_CHECK_IP"}, usage: "<limit>/<window> per client IP", value: rateLimitValue{&c.RateLimits.LocationCheckIP}},
+		{key: "rate_limits.location_check_user", env: []string{"RATE_LIMIT_LOCATION_CHECK_USER"}, usage: "<limit>/<window> per user_id", value: rateLimitValue{&c.RateLimits.LocationCheckUser}},
+		{key: "rate_limits.operator", env: []string{"RATE_LIMIT_OPERATOR"}, usage: "<limit>/<window> per operator", value: rateLimitValue{&c.RateLimits.Operator}},
+
+		{key: "idempotency.ttl_seconds", env: []string{"IDEMPOTENCY_TTL_SECONDS"}, usage: "how long responses are kept for replay", value: durationValue{&c.Idempotency.TTL, time.Second}},
+		{key: "cache.incidents_ttl_seconds", env: []string{"CACHE_INCIDENTS_TTL_SECONDS"}, usage: "active incidents cache TTL", value: durationValue{&c.Cache.IncidentsTTL, time.Second}},
+		{key: "cache.stale_ttl_seconds", env: []string{"CACHE_STALE_TTL_SECONDS"}, usage: "how long an expired value is still served while it is refreshed", value: durationValue{&c.Cache.StaleTTL, time.Second}},
+		{key: "cache.backend", env: []string{"CACHE_BACKEND"}, usage: "redis, memory (this process only) or two_tier (memory in front of redis)", value: stringValue{&c.Cache.Backend}},
+		{key: "cache.local_max_entries", env: []string{"CACHE_LOCAL_MAX_ENTRIES"}, usage: "in-process cache size", value: intValue{&c.Cache.LocalMaxEntries}},
+		{key: "cache.local_ttl_seconds", env: []string{"CACHE_LOCAL_TTL_SECONDS"}, usage: "how long two_tier serves a value from memory", value: durationValue{&c.Cache.LocalTTL, time.Second}},
+		{key: "cache.version_check_seconds", env: []string{"CACHE_VERSION_CHECK_SECONDS"}, usage: "how often cache versions are re-read from redis to catch missed invalidations", value: durationValue{&c.Cache.VersionCheck, time.Second}},
+		{key: "stats.window_minutes", env: []string{"STATS_TIME_WINDOW_MINUTES"}, usage: "stats window", value: intValue{&c.Stats.WindowMinutes}},
+		{key: "incidents.expiry_check_seconds", env: []string{"INCIDENT_EXPIRY_CHECK_SECONDS"}, usage: "expired incidents check period", value: durationValue{&c.Incidents.ExpiryCheckInterval, time.Second}},
+		{key: "degraded.snapshot_path", env: []string{"DEGRADED_SNAPSHOT_PATH"}, usage: "file keeping the last known good incident snapshots (empty: memory only)", value: stringValue{&c.Degraded.SnapshotPath}},
+		{key: "degraded.snapshot_max_age_seconds", env: []string{"DEGRADED_SNAPSHOT_MAX_AGE_SECONDS"}, usage: "oldest snapshot location checks are served from while postgres is down (0: never)", value: durationValue{&c.Degraded.SnapshotMaxAge, time.Second}},
+		{key: "degraded.spool_max_len", env: []string{"DEGRADED_SPOOL_MAX_LEN"}, usage: "location checks buffered in redis while postgres is down (0: drop them)", value: intValue{&c.Degraded.SpoolMaxLen}},
+		{key: "degraded.spool_replay_seconds", env: []string{"DEGRADED_SPOOL_REPLAY_SECONDS"}, usage: "how often spooled location checks are replayed", value: durationValue{&c.Degraded.SpoolReplay, time.Second}},
+
+		{key: "webhook.url", env: []string{"WEBHOOK_URL"}, usage: "legacy webhook receiver", secret: true, value: stringValue{&c.Webhook.URL}},
+		{key: "webhook.secret", env: []string{"WEBHOOK_SECRET"}, usage: "signing secret for webhook.url", secret: true, value: stringValue{&c.Webhook.Secret}},
+		{key: "webhook.max_retries", env: []string{"WEBHOOK_MAX_RETRIES"}, usage: "delivery retries", value: intValue{&c.Webhook.MaxRetries}},
+		{key: "webhook.retry_base_seconds", env: []string{"WEBHOOK_RETRY_BASE_SECONDS"}, usage: "first retry delay", value: durationValue{&c.Webhook.RetryBase, time.Second}},
+		{key: "webhook.retry_max_seconds", env: []string{"WEBHOOK_RETRY_MAX_SECONDS"}, usage: "retry delay cap", value: durationValue{&c.Webhook.RetryMax, time.Second}},
+		{key: "webhook.timeout_seconds", env: []string{"WEBHOOK_TIMEOUT_SECONDS"}, usage: "delivery request timeout", value: durationValue{&c.Webhook.Timeout, time.Second}},
+		{key: "webhook.workers", env: []string{"WEBHOOK_WORKERS"}, usage: "delivery workers", value: intValue{&c.Webhook.Workers}},
+		{key: "webhook.destination_concurrency", env: []string{"WEBHOOK_DESTINATION_CONCURRENCY"}, usage: "concurrent deliveries per receiver", value: intValue{&c.Webhook.DestinationConcurrency}},
+		{key: "webhook.breaker_threshold", env: []string{"WEBHOOK_BREAKER_THRESHOLD"}, usage: "failures that open the breaker", value: intValue{&c.Webhook.BreakerThreshold}},
+		{key: "webhook.breaker_cooldown_seconds", env: []string{"WEBHOOK_BREAKER_COOLDOWN_SECONDS"}, usage: "breaker cooldown", value: durationValue{&c.Webhook.BreakerCooldown, time.Second}},
+		{key: "webhook.batch_window_ms", env: []string{"WEBHOOK_BATCH_WINDOW_MS"}, usage: "batch window for webhook.url", value: durationValue{&c.Webhook.BatchWindow, time.Millisecond}},
+		{key: "webhook.batch_max_size", env: []string{"WEBHOOK_BATCH_MAX_SIZE"}, usage: "batch size for webhook.url", value: intValue{&c.Webhook.BatchMaxSize}},
+		{key: "webhook.digest_cooldown_seconds", env: []string{"WEBHOOK_DIGEST_COOLDOWN_SECONDS"}, usage: "digest cooldown for webhook.url", value: durationValue{&c.Webhook.DigestCooldown, time.Second}},
+
+		{key: "health.check_timeout_ms", env: []string{"HEALTH_CHECK_TIMEOUT_MS"}, usage: "timeout of each readiness check", value: durationValue{&c.Health.CheckTimeout, time.Millisecond}},
+		{key: "health.non_critical", env: []string{"HEALTH_NON_CRITICAL"}, usage: "comma-separated checks that only degrade readiness", value: listValue{&c.Health.NonCritical}},
+		{key: "health.heartbeat_max_age_seconds", env: []string{"HEALTH_HEARTBEAT_MAX_AGE_SECONDS"}, usage: "webhook worker heartbeat age that fails its check", value: durationValue{&c.Health.HeartbeatMaxAge, time.Second}},
+	}
+}
+
+type stringValue struct{ p *string }
+
+func (v stringValue) Set(raw string) error {
+	*v.p = raw
+	return nil
+}
+
+func (v stringValue) String() string { return *v.p }
+
+type intValue struct{ p *int }
+
+func (v intValue) Set(raw string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", raw)
+	}
+	*v.p = n
+	return nil
+}
+
+func (v intValue) String() string { return strconv.Itoa(*v.p) }
+
+type boolValue struct{ p *bool }
+
+func (v boolValue) Set(raw string) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("invalid boolean %q", raw)
+	}
+	*v.p = b
+	return nil
+}
+
+func (v boolValue) String() string { return strconv.FormatBool(*v.p) }
+
+func (v boolValue) IsBoolFlag() bool { return true }
+
+type floatValue struct{ p *float64 }
+
+func (v floatValue) Set(raw string) error {
+	f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", raw)
+	}
+	*v.p = f
+	return nil
+}
+
+func (v floatValue) String() string { return strconv.FormatFloat(*v.p, 'g', -1, 64) }
+
+// durationValue takes a plain number in unit, as the *_SECONDS variables
+// always did, or a Go duration such as "1m30s".
+type durationValue struct {
+	p    *time.Duration
+	unit time.Duration
+}
+
+func (v durationValue) Set(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		*v.p = time.Duration(n) * v.unit
+		return nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", raw)
+	}
+	*v.p = d
+	return nil
+}
+
+func (v durationValue) String() string {
+	if *v.p%v.unit == 0 {
+		return strconv.FormatInt(int64(*v.p/v.unit), 10)
+	}
+	return v.p.String()
+}
+
+type listValue struct{ p *[]string }
+
+func (v listValue) Set(raw string) error {
+	var out []string
+	for _, it := range strings.Split(raw, ",") {
+		if it = strings.TrimSpace(it); it != "" {
+			out = append(out, it)
+		}
+	}
+	*v.p = out
+	return nil
+}
+
+func (v listValue) String() string { return strings.Join(*v.p, ",") }
+
+// mapValue parses "a=b,c=d".
+type mapValue struct{ p *map[string]string }
+
+func (v mapValue) Set(raw string) error {
+	out := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, val, ok := strings.Cut(pair, "=")
+		k, val = strings.TrimSpace(k), strings.TrimSpace(val)
+		if !ok || k == "" || val == "" {
+			return fmt.Errorf("invalid pair %q: expected key=value", pair)
+		}
+		out[k] = val
+	}
+	*v.p = out
+	return nil
+}
+
+func (v mapValue) String() string {
+	pairs := make([]string, 0, len(*v.p))
+	for k, val := range *v.p {
+		pairs = append(pairs, k+"="+val)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+type rateLimitValue struct{ p *ratelimit.Limit }
+
+func (v rateLimitValue) Set(raw string) error {
+	limit, err := ratelimit.Parse(raw)
+	if err != nil {
+		return err
+	}
+	*v.p = limit
+	return nil
+}
+
+func (v rateLimitValue) String() string {
+	if v.p.Limit <= 0 || v.p.Window <= 0 {
+		return "0"
+	}
+	window := v.p.Window.String()
+	if strings.HasSuffix(window, "m0s") {
+		window = strings.TrimSuffix(window, "0s")
+	}
+	if strings.HasSuffix(window, "h0m") {
+		window = strings.TrimSuffix(window, "0m")
+	}
+	return strconv.Itoa(v.p.Limit) + "/" + window
+}
+
+type clientAuthModeValue struct{ p *clients.AuthMode }
+
+func (v clientAuthModeValue) Set(raw string) error {
+	*v.p = clients.AuthMode(strings.ToLower(strings.TrimSpace(raw)))
+	return nil
+}
+
+func (v clientAuthModeValue) String() string { return string(*v.p) }
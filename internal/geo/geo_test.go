@@ -0,0 +1,16 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceMeters(t *testing.T) {
+	if d := DistanceMeters(55.75, 37.61, 55.75, 37.61); d != 0 {
+		t.Fatalf("same point: %f", d)
+	}
+	// One degree of latitude is about 111.2 km.
+	if d := DistanceMeters(0, 0, 1, 0); math.Abs(d-111195) > 10 {
+		t.Fatalf("one degree: %f", d)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"RedColarTest/internal/incident/services"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,21 +20,23 @@ func NewIncidentHandler(svc *services.IncidentService) *IncidentHandler {
 }
 
 type createIncidentRequest struct {
-	Title         string  `json:"title" binding:"required"`
-	Description   *string `json:"description"`
-	Latitude      float64 `json:"latitude" binding:"required"`
-	Longitude     float64 `json:"longitude" binding:"required"`
-	DangerRadiusM int     `json:"danger_radius_m"`
-	IsActive      *bool   `json:"is_active"`
+	Title         string     `json:"title" binding:"required"`
+	Description   *string    `json:"description"`
+	Latitude      float64    `json:"latitude" binding:"required"`
+	Longitude     float64    `json:"longitude" binding:"required"`
+	DangerRadiusM int        `json:"danger_radius_m"`
+	IsActive      *bool      `json:"is_active"`
+	ExpiresAt     *time.Time `json:"expires_at"`
 }
 
 type updateIncidentRequest struct {
-	Title         string  `json:"title" binding:"required"`
-	Description   *string `json:"description"`
-	Latitude      float64 `json:"latitude" binding:"required"`
-	Longitude     float64 `json:"longitude" binding:"required"`
-	DangerRadiusM int     `json:"danger_radius_m" binding:"required"`
-	IsActive      bool    `json:"is_active" binding:"required"`
+	Title         string     `json:"title" binding:"required"`
+	Description   *string    `json:"description"`
+	Latitude      float64    `json:"latitude" binding:"required"`
+	Longitude     float64    `json:"longitude" binding:"required"`
+	DangerRadiusM int        `json:"danger_radius_m" binding:"required"`
+	IsActive      bool       `json:"is_active" binding:"required"`
+	ExpiresAt     *time.Time `json:"expires_at"`
 }
 
 func (h *IncidentHandler) Create(c *gin.Context) {
@@ -60,11 +63,14 @@ func (h *IncidentHandler) Create(c *gin.Context) {
 		Longitude:     req.Longitude,
 		DangerRadiusM: r,
 		IsActive:      isActive,
+		ExpiresAt:     req.ExpiresAt,
 	})
 	if err != nil {
 		switch err.Code {
 		case common.CodeIternalErr:
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		case common.CodeUnavailable:
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
 		case common.CodeNotValid:
 			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
 		default:
@@ -87,6 +93,10 @@ func (h *IncidentHandler) List(c *gin.Context) {
 
 	items, total, pageOut, sizeOut, err := h.svc.List(c.Request.Context(), page, pageSize, onlyActive)
 	if err != nil {
+		if err.Code == common.CodeUnavailable {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -112,6 +122,10 @@ func (h *IncidentHandler) GetByID(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
 		}
+		if errorDto.Code == common.CodeUnavailable {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": errorDto.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": errorDto.Error()})
 		return
 	}
@@ -139,12 +153,17 @@ func (h *IncidentHandler) Update(c *gin.Context) {
 		Longitude:     req.Longitude,
 		DangerRadiusM: req.DangerRadiusM,
 		IsActive:      req.IsActive,
+		ExpiresAt:     req.ExpiresAt,
 	})
 	if errorDto != nil {
 		if errorDto.Code == common.CodeNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
 		}
+		if errorDto.Code == common.CodeUnavailable {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": errorDto.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": errorDto.Error()})
 		return
 	}
@@ -165,6 +184,10 @@ func (h *IncidentHandler) Deactivate(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
 		}
+		if errorDto.Code == common.CodeUnavailable {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": errorDto.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": errorDto.Error()})
 		return
 	}
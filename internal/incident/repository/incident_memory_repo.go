@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"RedColarTest/internal/common"
+	"RedColarTest/internal/geo"
+	"RedColarTest/internal/incident/domain"
+	tenant "RedColarTest/internal/tenants/domain"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepo is an IncidentRepository kept in process memory with the same
+// tenant scoping and ordering as IncidentRepo. It backs tests.
+type MemoryRepo struct {
+	mu      sync.Mutex
+	nextID  int64
+	items   map[int64]domain.Incident
+	failure *common.Error
+}
+
+func NewMemoryRepo() *MemoryRepo {
+	return &MemoryRepo{items: make(map[int64]domain.Incident)}
+}
+
+func (r *MemoryRepo) Create(ctx context.Context, in domain.Incident) (domain.Incident, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return domain.Incident{}, cerr
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	now := time.Now().UTC()
+	in.ID = r.nextID
+	in.TenantID = tenantID
+	in.CreatedAt, in.UpdatedAt = now, now
+	r.items[in.ID] = in
+	return in, nil
+}
+
+func (r *MemoryRepo) GetByID(ctx context.Context, id int64) (domain.Incident, *common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.get(ctx, id)
+}
+
+func (r *MemoryRepo) List(ctx context.Context, limit, offset int, onlyActive bool) ([]domain.Incident, int64, *common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	all, err := r.scoped(ctx, func(in domain.Incident) bool { return !onlyActive || in.IsActive })
+	if err != nil {
+		return nil, 0, err
+	}
+	total := int64(len(all))
+	if offset >= len(all) {
+		return []domain.Incident{}, total, nil
+	}
+	all = all[offset:]
+	if limit < len(all) {
+		all = all[:limit]
+	}
+	return all, total, nil
+}
+
+func (r *MemoryRepo) ListActive(ctx context.Context) ([]domain.Incident, *common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failure != nil {
+		return nil, r.failure
+	}
+	now := time.Now()
+	return r.scoped(ctx, func(in domain.Incident) bool {
+		return in.IsActive && (in.ExpiresAt == nil || in.ExpiresAt.After(now))
+	})
+}
+
+func (r *MemoryRepo) Update(ctx context.Context, id int64, in domain.Incident) (domain.Incident, domain.Incident, *common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	before, err := r.get(ctx, id)
+	if err != nil {
+		return domain.Incident{}, domain.Incident{}, err
+	}
+	cur := before
+	cur.Title = in.Title
+	cur.Description = in.Description
+	cur.Latitude = in.Latitude
+	cur.Longitude = in.Longitude
+	cur.DangerRadiusM = in.DangerRadiusM
+	cur.IsActive = in.IsActive
+	cur.ExpiresAt = in.ExpiresAt
+	cur.UpdatedAt = time.Now().UTC()
+	r.items[id] = cur
+	return before, cur, nil
+}
+
+func (r *MemoryRepo) Deactivate(ctx context.Context, id int64) (domain.Incident, *common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cur, err := r.get(ctx, id)
+	if err != nil {
+		return domain.Incident{}, err
+	}
+	if !cur.IsActive {
+		return domain.Incident{}, common.NewError(common.CodeNotFound, "no rows in result set")
+	}
+	return r.deactivate(cur, time.Now().UTC()), nil
+}
+
+func (r *MemoryRepo) DeactivateWithin(ctx context.Context, lat, lon, radiusM float64) ([]domain.Incident, *common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().UTC()
+	out, err := r.scoped(ctx, func(in domain.Incident) bool {
+		return in.IsActive && geo.DistanceMeters(lat, lon, in.Latitude, in.Longitude) <= radiusM
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, in := range out {
+		out[i] = r.deactivate(in, now)
+	}
+	return out, nil
+}
+
+func (r *MemoryRepo) ExpireDue(_ context.Context, now time.Time) ([]domain.Incident, *common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.Incident
+	for _, in := range r.items {
+		if in.IsActive && in.ExpiresAt != nil && !in.ExpiresAt.After(now) {
+			out = append(out, r.deactivate(in, now))
+		}
+	}
+	sortByIDDesc(out)
+	return out, nil
+}
+
+// Fail makes ListActive return err until Fail(nil), as if Postgres were down
+// for location checks.
+func (r *MemoryRepo) Fail(err *common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failure = err
+}
+
+func (r *MemoryRepo) get(ctx context.Context, id int64) (domain.Incident, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return domain.Incident{}, cerr
+	}
+	in, ok := r.items[id]
+	if !ok || in.TenantID != tenantID {
+		return domain.Incident{}, common.NewError(common.CodeNotFound, fmt.Sprintf("incident %d not found", id))
+	}
+	return in, nil
+}
+
+func (r *MemoryRepo) scoped(ctx context.Context, keep func(domain.Incident) bool) ([]domain.Incident, *common.Error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]domain.Incident, 0)
+	for _, in := range r.items {
+		if in.TenantID == tenantID && keep(in) {
+			out = append(out, in)
+		}
+	}
+	sortByIDDesc(out)
+	return out, nil
+}
+
+func (r *MemoryRepo) deactivate(in domain.Incident, now time.Time) domain.Incident {
+	in.IsActive = false
+	in.UpdatedAt = now
+	r.items[in.ID] = in
+	return in
+}
+
+func sortByIDDesc(items []domain.Incident) {
+	sort.Slice(items, func(i, j int) bool { return items[i].ID > items[j].ID })
+}
@@ -3,174 +3,154 @@ package repository
 import (
 	"RedColarTest/internal/common"
 	"RedColarTest/internal/incident/domain"
+	"RedColarTest/internal/logging"
+	tenant "RedColarTest/internal/tenants/domain"
 	"context"
 	"errors"
+	"log/slog"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const incidentColumns = `id, tenant_id, title, description, latitude, longitude, danger_radius_m, is_active, expires_at, created_at, updated_at`
+
 type IncidentRepo struct {
-	db *pgxpool.Pool
+	db  *pgxpool.Pool
+	log *slog.Logger
 }
 
-func NewIncidentRepo(db *pgxpool.Pool) *IncidentRepo {
-	return &IncidentRepo{db: db}
+func NewIncidentRepo(db *pgxpool.Pool, logger *slog.Logger) *IncidentRepo {
+	return &IncidentRepo{db: db, log: logging.OrDiscard(logger)}
 }
 
 func (r *IncidentRepo) Create(ctx context.Context, in domain.Incident) (domain.Incident, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return domain.Incident{}, cerr
+	}
 	const q = `
-insert into incidents (title, description, latitude, longitude, danger_radius_m, is_active)
-values ($1, $2, $3, $4, $5, $6)
-returning id, title, description, latitude, longitude, danger_radius_m, is_active, created_at, updated_at;
+insert into incidents (tenant_id, title, description, latitude, longitude, danger_radius_m, is_active, expires_at)
+values ($1, $2, $3, $4, $5, $6, $7, $8)
+returning ` + incidentColumns + `;
 `
-	var out domain.Incident
-	err := r.db.QueryRow(ctx, q,
+	out, err := scanIncident(r.db.QueryRow(ctx, q,
+		tenantID,
 		in.Title,
 		in.Description,
 		in.Latitude,
 		in.Longitude,
 		in.DangerRadiusM,
 		in.IsActive,
-	).Scan(
-		&out.ID,
-		&out.Title,
-		&out.Description,
-		&out.Latitude,
-		&out.Longitude,
-		&out.DangerRadiusM,
-		&out.IsActive,
-		&out.CreatedAt,
-		&out.UpdatedAt,
-	)
+		in.ExpiresAt,
+	))
 	if err != nil {
-		return domain.Incident{}, common.NewError(common.CodeIternalErr, err.Error())
+		return domain.Incident{}, common.Internal(ctx, r.log, "IncidentRepo.Create", err)
 	}
 	return out, nil
 }
 
 func (r *IncidentRepo) GetByID(ctx context.Context, id int64) (domain.Incident, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return domain.Incident{}, cerr
+	}
 	const q = `
-select id, title, description, latitude, longitude, danger_radius_m, is_active, created_at, updated_at
+select ` + incidentColumns + `
 from incidents
-where id = $1;
+where id = $1 and tenant_id = $2;
 `
-	var out domain.Incident
-	err := r.db.QueryRow(ctx, q, id).Scan(
-		&out.ID,
-		&out.Title,
-		&out.Description,
-		&out.Latitude,
-		&out.Longitude,
-		&out.DangerRadiusM,
-		&out.IsActive,
-		&out.CreatedAt,
-		&out.UpdatedAt,
-	)
+	out, err := scanIncident(r.db.QueryRow(ctx, q, id, tenantID))
 	if errors.Is(err, pgx.ErrNoRows) {
 		return domain.Incident{}, common.NewError(common.CodeNotFound, err.Error())
 	}
 	if err != nil {
-		return domain.Incident{}, common.NewError(common.CodeIternalErr, err.Error())
+		return domain.Incident{}, common.Internal(ctx, r.log, "IncidentRepo.GetByID", err)
 	}
 	return out, nil
 }
 
 func (r *IncidentRepo) List(ctx context.Context, limit, offset int, onlyActive bool) ([]domain.Incident, int64, *common.Error) {
-	totalQ := `select count(1) from incidents where ($1 = false) or (is_active = true);`
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return nil, 0, cerr
+	}
+	totalQ := `select count(1) from incidents where tenant_id = $1 and (($2 = false) or (is_active = true));`
 	var total int64
-	if err := r.db.QueryRow(ctx, totalQ, onlyActive).Scan(&total); err != nil {
-		return nil, 0, common.NewError(common.CodeIternalErr, err.Error())
+	if err := r.db.QueryRow(ctx, totalQ, tenantID, onlyActive).Scan(&total); err != nil {
+		return nil, 0, common.Internal(ctx, r.log, "IncidentRepo.List", err)
 	}
 
 	const q = `
-    select id, title, description, latitude, longitude, danger_radius_m, is_active, created_at, updated_at
+    select ` + incidentColumns + `
     from incidents
-    where ($1 = false) or (is_active = true)
+    where tenant_id = $1 and (($2 = false) or (is_active = true))
     order by id desc
-    limit $2 offset $3;
+    limit $3 offset $4;
     `
-	rows, err := r.db.Query(ctx, q, onlyActive, limit, offset)
+	rows, err := r.db.Query(ctx, q, tenantID, onlyActive, limit, offset)
 	if err != nil {
-		return nil, 0, common.NewError(common.CodeIternalErr, err.Error())
+		return nil, 0, common.Internal(ctx, r.log, "IncidentRepo.List", err)
 	}
-	defer rows.Close()
-
-	items := make([]domain.Incident, 0, limit)
-	for rows.Next() {
-		var it domain.Incident
-		if err := rows.Scan(
-			&it.ID,
-			&it.Title,
-			&it.Description,
-			&it.Latitude,
-			&it.Longitude,
-			&it.DangerRadiusM,
-			&it.IsActive,
-			&it.CreatedAt,
-			&it.UpdatedAt,
-		); err != nil {
-			return nil, 0, common.NewError(common.CodeIternalErr, err.Error())
-		}
-		items = append(items, it)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, 0, common.NewError(common.CodeIternalErr, err.Error())
+	items, scanErr := r.scanIncidents(ctx, rows, limit)
+	if scanErr != nil {
+		return nil, 0, scanErr
 	}
 	return items, total, nil
 }
 
 func (r *IncidentRepo) ListActive(ctx context.Context) ([]domain.Incident, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return nil, cerr
+	}
 	const q = `
-    select id, title, description, latitude, longitude, danger_radius_m, is_active, created_at, updated_at
+    select ` + incidentColumns + `
     from incidents
-    where is_active = true
+    where tenant_id = $1 and is_active = true
+      and (expires_at is null or expires_at > now())
     order by id desc;
     `
-	rows, err := r.db.Query(ctx, q)
+	rows, err := r.db.Query(ctx, q, tenantID)
 	if err != nil {
-		return nil, common.NewError(common.CodeIternalErr, err.Error())
-	}
-	defer rows.Close()
-
-	items := make([]domain.Incident, 0)
-	for rows.Next() {
-		var it domain.Incident
-		if err := rows.Scan(
-			&it.ID,
-			&it.Title,
-			&it.Description,
-			&it.Latitude,
-			&it.Longitude,
-			&it.DangerRadiusM,
-			&it.IsActive,
-			&it.CreatedAt,
-			&it.UpdatedAt,
-		); err != nil {
-			return nil, common.NewError(common.CodeIternalErr, err.Error())
-		}
-		items = append(items, it)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, common.NewError(common.CodeIternalErr, err.Error())
+		return nil, common.Internal(ctx, r.log, "IncidentRepo.ListActive", err)
 	}
-	return items, nil
+	return r.scanIncidents(ctx, rows, 0)
 }
 
-func (r *IncidentRepo) Update(ctx context.Context, id int64, in domain.Incident) (domain.Incident, *common.Error) {
+// Update overwrites an incident and returns it as it was before and after the
+// change. The old row is read and locked in the same statement, so a
+// concurrent update cannot slip in between.
+func (r *IncidentRepo) Update(ctx context.Context, id int64, in domain.Incident) (domain.Incident, domain.Incident, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return domain.Incident{}, domain.Incident{}, cerr
+	}
 	const q = `
-        update incidents
+        with old as (
+            select ` + incidentColumns + `
+            from incidents
+            where id = $1 and tenant_id = $9
+            for update
+        )
+        update incidents i
         set title = $2,
         description = $3,
         latitude = $4,
         longitude = $5,
         danger_radius_m = $6,
         is_active = $7,
+        expires_at = $8,
         updated_at = now()
-        where id = $1
-        returning id, title, description, latitude, longitude, danger_radius_m, is_active, created_at, updated_at;
+        from old
+        where i.id = old.id
+        returning i.id, i.tenant_id, i.title, i.description, i.latitude, i.longitude, i.danger_radius_m,
+            i.is_active, i.expires_at, i.created_at, i.updated_at,
+            old.id, old.tenant_id, old.title, old.description, old.latitude, old.longitude, old.danger_radius_m,
+            old.is_active, old.expires_at, old.created_at, old.updated_at;
 `
-	var out domain.Incident
+	var before, after domain.Incident
 	err := r.db.QueryRow(ctx, q,
 		id,
 		in.Title,
@@ -179,52 +159,121 @@ func (r *IncidentRepo) Update(ctx context.Context, id int64, in domain.Incident)
 		in.Longitude,
 		in.DangerRadiusM,
 		in.IsActive,
-	).Scan(
-		&out.ID,
-		&out.Title,
-		&out.Description,
-		&out.Latitude,
-		&out.Longitude,
-		&out.DangerRadiusM,
-		&out.IsActive,
-		&out.CreatedAt,
-		&out.UpdatedAt,
-	)
+		in.ExpiresAt,
+		tenantID,
+	).Scan(append(incidentFields(&after), incidentFields(&before)...)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Incident{}, domain.Incident{}, common.NewError(common.CodeNotFound, err.Error())
+	}
+	if err != nil {
+		return domain.Incident{}, domain.Incident{}, common.Internal(ctx, r.log, "IncidentRepo.Update", err)
+	}
+	return before, after, nil
+}
+
+func (r *IncidentRepo) Deactivate(ctx context.Context, id int64) (domain.Incident, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return domain.Incident{}, cerr
+	}
+	const q = `
+    update incidents
+    set is_active = false,
+        deactivated_at = now(),
+        updated_at = now()
+    where id = $1 and tenant_id = $2 and is_active = true
+    returning ` + incidentColumns + `;
+`
+	out, err := scanIncident(r.db.QueryRow(ctx, q, id, tenantID))
 	if errors.Is(err, pgx.ErrNoRows) {
 		return domain.Incident{}, common.NewError(common.CodeNotFound, err.Error())
 	}
 	if err != nil {
-		return domain.Incident{}, common.NewError(common.CodeIternalErr, err.Error())
+		return domain.Incident{}, common.Internal(ctx, r.log, "IncidentRepo.Deactivate", err)
 	}
 	return out, nil
 }
 
-func (r *IncidentRepo) Deactivate(ctx context.Context, id int64) (domain.Incident, *common.Error) {
+// DeactivateWithin deactivates the active incidents of the context tenant
+// whose centre lies within radiusM of the point (haversine distance).
+func (r *IncidentRepo) DeactivateWithin(ctx context.Context, lat, lon, radiusM float64) ([]domain.Incident, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return nil, cerr
+	}
 	const q = `
     update incidents
     set is_active = false,
         deactivated_at = now(),
         updated_at = now()
-    where id = $1 and is_active = true
-    returning id, title, description, latitude, longitude, danger_radius_m, is_active, created_at, updated_at;
+    where tenant_id = $1 and is_active = true
+      and 2 * 6371000 * asin(sqrt(
+            power(sin(radians(latitude - $2) / 2), 2) +
+            cos(radians($2)) * cos(radians(latitude)) * power(sin(radians(longitude - $3) / 2), 2)
+          )) <= $4
+    returning ` + incidentColumns + `;
+`
+	rows, err := r.db.Query(ctx, q, tenantID, lat, lon, radiusM)
+	if err != nil {
+		return nil, common.Internal(ctx, r.log, "IncidentRepo.DeactivateWithin", err)
+	}
+	return r.scanIncidents(ctx, rows, 0)
+}
+
+// ExpireDue runs across all tenants; callers use Incident.TenantID to scope
+// follow-up work.
+func (r *IncidentRepo) ExpireDue(ctx context.Context, now time.Time) ([]domain.Incident, *common.Error) {
+	const q = `
+    update incidents
+    set is_active = false,
+        deactivated_at = $1,
+        updated_at = $1
+    where is_active = true and expires_at is not null and expires_at <= $1
+    returning ` + incidentColumns + `;
 `
+	rows, err := r.db.Query(ctx, q, now)
+	if err != nil {
+		return nil, common.Internal(ctx, r.log, "IncidentRepo.ExpireDue", err)
+	}
+	return r.scanIncidents(ctx, rows, 0)
+}
+
+func scanIncident(row pgx.Row) (domain.Incident, error) {
 	var out domain.Incident
-	err := r.db.QueryRow(ctx, q, id).Scan(
+	err := row.Scan(incidentFields(&out)...)
+	return out, err
+}
+
+// incidentFields returns scan destinations in incidentColumns order.
+func incidentFields(out *domain.Incident) []any {
+	return []any{
 		&out.ID,
+		&out.TenantID,
 		&out.Title,
 		&out.Description,
 		&out.Latitude,
 		&out.Longitude,
 		&out.DangerRadiusM,
 		&out.IsActive,
+		&out.ExpiresAt,
 		&out.CreatedAt,
 		&out.UpdatedAt,
-	)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return domain.Incident{}, common.NewError(common.CodeNotFound, err.Error())
 	}
-	if err != nil {
-		return domain.Incident{}, common.NewError(common.CodeIternalErr, err.Error())
+}
+
+func (r *IncidentRepo) scanIncidents(ctx context.Context, rows pgx.Rows, capacity int) ([]domain.Incident, *common.Error) {
+	defer rows.Close()
+
+	items := make([]domain.Incident, 0, capacity)
+	for rows.Next() {
+		it, err := scanIncident(rows)
+		if err != nil {
+			return nil, common.Internal(ctx, r.log, "IncidentRepo.scanIncidents", err)
+		}
+		items = append(items, it)
 	}
-	return out, nil
+	if err := rows.Err(); err != nil {
+		return nil, common.Internal(ctx, r.log, "IncidentRepo.scanIncidents", err)
+	}
+	return items, nil
 }
@@ -4,6 +4,7 @@ import (
 	"RedColarTest/internal/common"
 	"RedColarTest/internal/incident/domain"
 	"context"
+	"time"
 )
 
 type IncidentRepository interface {
@@ -15,7 +16,12 @@ type IncidentRepository interface {
 
 	ListActive(ctx context.Context) ([]domain.Incident, *common.Error)
 
-	Update(ctx context.Context, id int64, in domain.Incident) (domain.Incident, *common.Error)
+	// Update returns the incident before and after the change.
+	Update(ctx context.Context, id int64, in domain.Incident) (domain.Incident, domain.Incident, *common.Error)
 
 	Deactivate(ctx context.Context, id int64) (domain.Incident, *common.Error)
+
+	DeactivateWithin(ctx context.Context, lat, lon, radiusM float64) ([]domain.Incident, *common.Error)
+
+	ExpireDue(ctx context.Context, now time.Time) ([]domain.Incident, *common.Error)
 }
@@ -1,29 +1,46 @@
 package services
 
 import (
+	"RedColarTest/internal/cache"
 	"RedColarTest/internal/common"
 	"RedColarTest/internal/incident/domain"
 	"RedColarTest/internal/incident/repository"
+	"RedColarTest/internal/logging"
+	tenant "RedColarTest/internal/tenants/domain"
+	"RedColarTest/internal/webhook"
 	"context"
 	"fmt"
-
-	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"time"
 )
 
 type IncidentService struct {
-	repo     repository.IncidentRepository
-	cache    *redis.Client
-	cacheKey string
+	repo       repository.IncidentRepository
+	cache      cache.Cache
+	versions   cache.Versions
+	cacheScope string
+	webhookQ   webhook.Enqueuer
+	log        *slog.Logger
 }
 
-func NewIncidentService(repo repository.IncidentRepository, cache *redis.Client) *IncidentService {
-	return &IncidentService{repo: repo, cache: cache, cacheKey: "cache:active_incidents"}
+func NewIncidentService(repo repository.IncidentRepository, c cache.Cache, versions cache.Versions, webhookQ webhook.Enqueuer, logger *slog.Logger) *IncidentService {
+	return &IncidentService{
+		repo:       repo,
+		cache:      c,
+		versions:   versions,
+		cacheScope: "active_incidents",
+		webhookQ:   webhookQ,
+		log:        logging.OrDiscard(logger),
+	}
 }
 
 func (s *IncidentService) Create(ctx context.Context, in domain.Incident) (*domain.Incident, *common.Error) {
 	if err := validateIncident(in); err != nil {
 		return &domain.Incident{}, common.NewError(common.CodeNotValid, err.Error())
 	}
+	if in.ExpiresAt != nil && !in.ExpiresAt.After(time.Now()) {
+		return &domain.Incident{}, common.NewError(common.CodeNotValid, "expires_at must be in the future")
+	}
 
 	incident, err := s.repo.Create(ctx, in)
 
@@ -31,6 +48,7 @@ func (s *IncidentService) Create(ctx context.Context, in domain.Incident) (*doma
 		return nil, err
 	}
 	s.invalidateCache(ctx)
+	s.publish(ctx, webhook.EventIncidentCreated, nil, incident)
 	return &incident, nil
 }
 
@@ -68,9 +86,13 @@ func (s *IncidentService) Update(ctx context.Context, id int64, in domain.Incide
 	if err := validateIncident(in); err != nil {
 		return domain.Incident{}, common.NewError(common.CodeNotValid, err.Error())
 	}
-	out, err := s.repo.Update(ctx, id, in)
+	if in.ExpiresAt != nil && !in.ExpiresAt.After(time.Now()) {
+		return domain.Incident{}, common.NewError(common.CodeNotValid, "expires_at must be in the future")
+	}
+	before, out, err := s.repo.Update(ctx, id, in)
 	if err == nil {
 		s.invalidateCache(ctx)
+		s.publish(ctx, webhook.EventIncidentUpdated, &before, out)
 	}
 	return out, err
 }
@@ -82,10 +104,81 @@ func (s *IncidentService) Deactivate(ctx context.Context, id int64) (domain.Inci
 	out, err := s.repo.Deactivate(ctx, id)
 	if err == nil {
 		s.invalidateCache(ctx)
+		s.publish(ctx, webhook.EventIncidentDeactivated, nil, out)
 	}
 	return out, err
 }
 
+// DeactivateArea deactivates every active incident centred within radiusM of
+// the point and publishes a deactivation event for each.
+func (s *IncidentService) DeactivateArea(ctx context.Context, lat, lon, radiusM float64) ([]domain.Incident, *common.Error) {
+	if lat < -90 || lat > 90 {
+		return nil, common.NewError(common.CodeNotValid, "latitude out of range")
+	}
+	if lon < -180 || lon > 180 {
+		return nil, common.NewError(common.CodeNotValid, "longitude out of range")
+	}
+	if radiusM <= 0 {
+		return nil, common.NewError(common.CodeNotValid, "radius must be > 0")
+	}
+	out, err := s.repo.DeactivateWithin(ctx, lat, lon, radiusM)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > 0 {
+		s.invalidateCache(ctx)
+	}
+	for _, inc := range out {
+		s.publish(ctx, webhook.EventIncidentDeactivated, nil, inc)
+	}
+	return out, nil
+}
+
+// FlushCache bumps the cache version of the context tenant so the next check
+// on every instance reads the active incidents from Postgres.
+func (s *IncidentService) FlushCache(ctx context.Context) *common.Error {
+	if err := s.bumpCache(ctx); err != nil {
+		return common.NewError(common.CodeUnavailable, err.Error())
+	}
+	return nil
+}
+
+func (s *IncidentService) ExpireDue(ctx context.Context) (int, *common.Error) {
+	expired, err := s.repo.ExpireDue(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+	for _, inc := range expired {
+		s.log.InfoContext(ctx, "incident expired", "incident_id", inc.ID, "tenant_id", inc.TenantID)
+		tenantCtx := tenant.WithID(ctx, inc.TenantID)
+		s.invalidateCache(tenantCtx)
+		s.publish(tenantCtx, webhook.EventIncidentExpired, nil, inc)
+	}
+	return len(expired), nil
+}
+
+func (s *IncidentService) RunExpirer(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			runCtx := logging.WithRequestID(ctx, logging.NewRequestID())
+			if _, err := s.ExpireDue(runCtx); err != nil {
+				s.log.ErrorContext(runCtx, "expire incidents failed", "err", err)
+			}
+		}
+	}
+}
+
 func validateIncident(in domain.Incident) error {
 	if in.Title == "" {
 		return fmt.Errorf("title is required")
@@ -103,8 +196,63 @@ func validateIncident(in domain.Incident) error {
 }
 
 func (s *IncidentService) invalidateCache(ctx context.Context) {
-	if s.cache == nil {
+	if err := s.bumpCache(ctx); err != nil {
+		s.log.ErrorContext(ctx, "invalidate incidents cache failed", "err", err)
+	}
+}
+
+// bumpCache moves the tenant to a new cache version, which other instances
+// learn about through the invalidation bus. If the bump fails the current
+// entry is deleted instead, which only helps instances sharing this cache.
+func (s *IncidentService) bumpCache(ctx context.Context) error {
+	if s.cache == nil || s.versions == nil {
+		return nil
+	}
+	scope, cerr := tenant.ScopedKey(ctx, s.cacheScope)
+	if cerr != nil {
+		return cerr
+	}
+	// Versions may jump (e.g. after a Redis restart), so the entry to drop is
+	// the one read before the bump, not v-1.
+	cur, curErr := s.versions.Current(ctx, scope)
+	_, err := s.versions.Bump(ctx, scope)
+	if curErr == nil {
+		// Unreachable after a successful bump; deleting it only frees memory.
+		_ = s.cache.Delete(ctx, cache.VersionedKey(scope, cur))
+	}
+	return err
+}
+
+func (s *IncidentService) publish(ctx context.Context, eventType webhook.EventType, before *domain.Incident, after domain.Incident) {
+	if s.webhookQ == nil {
 		return
 	}
-	_ = s.cache.Del(ctx, s.cacheKey).Err()
+	data := webhook.IncidentEventData{After: toSnapshot(after)}
+	if before != nil {
+		snap := toSnapshot(*before)
+		data.Before = &snap
+	}
+	event, err := webhook.NewEvent(eventType, data)
+	if err != nil {
+		s.log.ErrorContext(ctx, "build webhook event failed", "type", eventType, "err", err)
+		return
+	}
+	if err := s.webhookQ.Publish(ctx, event); err != nil {
+		s.log.ErrorContext(ctx, "publish webhook event failed", "type", eventType, "event_id", event.ID, "err", err)
+	}
+}
+
+func toSnapshot(in domain.Incident) webhook.IncidentSnapshot {
+	return webhook.IncidentSnapshot{
+		ID:            in.ID,
+		Title:         in.Title,
+		Description:   in.Description,
+		Latitude:      in.Latitude,
+		Longitude:     in.Longitude,
+		DangerRadiusM: in.DangerRadiusM,
+		IsActive:      in.IsActive,
+		ExpiresAt:     in.ExpiresAt,
+		CreatedAt:     in.CreatedAt,
+		UpdatedAt:     in.UpdatedAt,
+	}
 }
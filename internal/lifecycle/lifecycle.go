@@ -0,0 +1,142 @@
+package lifecycle
+
+import (
+	"RedColarTest/internal/logging"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type step struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Manager waits for a termination signal or a fatal component error and then
+// runs the registered stop steps in registration order under one deadline.
+type Manager struct {
+	log     *slog.Logger
+	timeout time.Duration
+	steps   []step
+	failed  chan error
+}
+
+func New(logger *slog.Logger, timeout time.Duration) *Manager {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &Manager{
+		log:     logging.OrDiscard(logger),
+		timeout: timeout,
+		failed:  make(chan error, 1),
+	}
+}
+
+// OnStop registers a stop step. Steps run one after another so that, for
+// example, the HTTP server is drained before the pools it uses are closed.
+func (m *Manager) OnStop(name string, stop func(ctx context.Context) error) {
+	m.steps = append(m.steps, step{name: name, stop: stop})
+}
+
+// Go runs a component whose return means the process cannot continue, such as
+// the HTTP listener. A nil error or http.ErrServerClosed is not a failure.
+func (m *Manager) Go(name string, run func() error) {
+	go func() {
+		if err := run(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			select {
+			case m.failed <- &componentError{name: name, err: err}:
+			default:
+			}
+		}
+	}()
+}
+
+// Run blocks until SIGINT/SIGTERM, ctx cancellation or a component failure,
+// then stops everything. It returns the component error, if any, so main can
+// pick the exit code.
+func (m *Manager) Run(ctx context.Context) error {
+	sigCtx, stopSignals := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	var cause error
+	select {
+	case <-sigCtx.Done():
+		m.log.Info("shutdown requested")
+	case cause = <-m.failed:
+		m.log.Error("component failed, shutting down", "err", cause)
+	}
+	// A second signal falls back to the default behaviour and kills the process.
+	stopSignals()
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	for _, s := range m.steps {
+		start := time.Now()
+		if err := s.stop(stopCtx); err != nil {
+			m.log.Error("shutdown step failed", "step", s.name, "err", err)
+			continue
+		}
+		m.log.Info("shutdown step done", "step", s.name, "took_ms", time.Since(start).Milliseconds())
+	}
+	return cause
+}
+
+// Group runs background goroutines that are cancelled and awaited on stop.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewGroup() *Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Group{ctx: ctx, cancel: cancel}
+}
+
+func (g *Group) Go(run func(ctx context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		run(g.ctx)
+	}()
+}
+
+// Stop cancels the group and waits for its goroutines until ctx is done.
+func (g *Group) Stop(ctx context.Context) error {
+	g.cancel()
+	return Wait(ctx, &g.wg)
+}
+
+// Wait waits for wg until ctx is done.
+func Wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type componentError struct {
+	name string
+	err  error
+}
+
+func (e *componentError) Error() string {
+	return e.name + ": " + e.err.Error()
+}
+
+func (e *componentError) Unwrap() error {
+	return e.err
+}
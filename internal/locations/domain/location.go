@@ -5,6 +5,7 @@ import "time"
 type LocationCheck struct {
 	ID        int64     `json:"id"`
 	UserID    string    `json:"user_id"`
+	ClientID  string    `json:"client_id,omitempty"`
 	Latitude  float64   `json:"latitude"`
 	Longitude float64   `json:"longitude"`
 	HasDanger bool      `json:"has_danger"`
@@ -24,4 +25,8 @@ type IncidentDistance struct {
 type CheckResult struct {
 	Dangerous bool               `json:"dangerous"`
 	Incidents []IncidentDistance `json:"incidents"`
+	// Stale is set when the incidents come from the last known good snapshot
+	// because the incident store was unavailable; SnapshotAge is its age.
+	Stale       bool          `json:"stale"`
+	SnapshotAge time.Duration `json:"-"`
 }
@@ -2,10 +2,13 @@ package location
 
 import (
 	"RedColarTest/internal/common"
+	"RedColarTest/internal/lifecycle"
 	location "RedColarTest/internal/locations/services"
+	"RedColarTest/internal/logging"
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,10 +17,13 @@ import (
 type Handler struct {
 	svc                *location.Service
 	statsWindowMinutes int
+	log                *slog.Logger
+	// recording tracks RecordCheck goroutines that outlive their request.
+	recording sync.WaitGroup
 }
 
-func NewLocationHandler(svc *location.Service, statsWindowMinutes int) *Handler {
-	return &Handler{svc: svc, statsWindowMinutes: statsWindowMinutes}
+func NewLocationHandler(svc *location.Service, statsWindowMinutes int, logger *slog.Logger) *Handler {
+	return &Handler{svc: svc, statsWindowMinutes: statsWindowMinutes, log: logging.OrDiscard(logger)}
 }
 
 type LocationCheckRequest struct {
@@ -40,26 +46,43 @@ func (h *Handler) LocationCheckHandler(ctx *gin.Context) {
 			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Text, "code": err.Code})
 		case common.CodeNotFound:
 			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Text, "code": err.Code})
+		case common.CodeUnavailable:
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Text, "code": err.Code})
 		default:
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Text, "code": err.Code})
 		}
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
+	body := gin.H{
 		"dangerous": res.Dangerous,
 		"incidents": res.Incidents,
-	})
+		"stale":     res.Stale,
+	}
+	if res.Stale {
+		body["snapshot_age_seconds"] = int64(res.SnapshotAge.Seconds())
+	}
+	ctx.JSON(http.StatusOK, body)
 
+	// Detached from the request but keeps its values (tenant).
+	reqCtx := context.WithoutCancel(ctx.Request.Context())
+	h.recording.Add(1)
 	go func() {
-		bg, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer h.recording.Done()
+		bg, cancel := context.WithTimeout(reqCtx, 5*time.Second)
 		defer cancel()
 		if _, err := h.svc.RecordCheck(bg, req.UserID, req.Latitude, req.Longitude, res.Incidents); err != nil {
-			log.Println("record check failed:", err)
+			h.log.ErrorContext(bg, "record check failed", "user_id", req.UserID, "err", err)
 		}
 	}()
 }
 
+// Wait blocks until checks accepted so far are recorded or ctx is done. Call it
+// after the HTTP server has stopped taking requests.
+func (h *Handler) Wait(ctx context.Context) error {
+	return lifecycle.Wait(ctx, &h.recording)
+}
+
 func (h *Handler) StatsHandler(ctx *gin.Context) {
 	count, err := h.svc.Stats(ctx.Request.Context(), h.statsWindowMinutes)
 	if err != nil {
@@ -67,6 +90,10 @@ func (h *Handler) StatsHandler(ctx *gin.Context) {
 			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Text})
 			return
 		}
+		if err.Code == common.CodeUnavailable {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Text, "code": err.Code})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Text, "code": err.Code})
 		return
 	}
@@ -0,0 +1,81 @@
+package location
+
+import (
+	"RedColarTest/internal/common"
+	domain "RedColarTest/internal/locations/domain"
+	tenant "RedColarTest/internal/tenants/domain"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRepo is a LocationRepository kept in process memory. It backs tests.
+type MemoryRepo struct {
+	mu      sync.Mutex
+	checks  []memoryCheck
+	failure *common.Error
+}
+
+type memoryCheck struct {
+	tenantID int64
+	check    domain.LocationCheck
+}
+
+func NewMemoryRepo() *MemoryRepo {
+	return &MemoryRepo{}
+}
+
+func (r *MemoryRepo) SaveCheck(ctx context.Context, in domain.LocationCheck) (int64, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return 0, cerr
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failure != nil {
+		return 0, r.failure
+	}
+	in.ID = int64(len(r.checks) + 1)
+	if in.CreatedAt.IsZero() {
+		in.CreatedAt = time.Now().UTC()
+	}
+	r.checks = append(r.checks, memoryCheck{tenantID: tenantID, check: in})
+	return in.ID, nil
+}
+
+func (r *MemoryRepo) CountUniqueUsersSince(ctx context.Context, since time.Time) (int64, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return 0, cerr
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	users := make(map[string]struct{})
+	for _, c := range r.checks {
+		if c.tenantID == tenantID && !c.check.CreatedAt.Before(since) {
+			users[c.check.UserID] = struct{}{}
+		}
+	}
+	return int64(len(users)), nil
+}
+
+// Fail makes SaveCheck return err until Fail(nil), as if Postgres were down.
+func (r *MemoryRepo) Fail(err *common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failure = err
+}
+
+// Checks returns the recorded checks of the context tenant in order.
+func (r *MemoryRepo) Checks(ctx context.Context) []domain.LocationCheck {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tenantID, _ := tenant.ID(ctx)
+	var out []domain.LocationCheck
+	for _, c := range r.checks {
+		if c.tenantID == tenantID {
+			out = append(out, c.check)
+		}
+	}
+	return out
+}
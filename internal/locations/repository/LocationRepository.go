@@ -3,38 +3,62 @@ package location
 import (
 	"RedColarTest/internal/common"
 	domain "RedColarTest/internal/locations/domain"
+	"RedColarTest/internal/logging"
+	tenant "RedColarTest/internal/tenants/domain"
 	"context"
+	"log/slog"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+type LocationRepository interface {
+	SaveCheck(ctx context.Context, in domain.LocationCheck) (int64, *common.Error)
+
+	CountUniqueUsersSince(ctx context.Context, since time.Time) (int64, *common.Error)
+}
+
 type Repo struct {
-	db *pgxpool.Pool
+	db  *pgxpool.Pool
+	log *slog.Logger
 }
 
-func NewLocationRepo(db *pgxpool.Pool) *Repo {
-	return &Repo{db: db}
+func NewLocationRepo(db *pgxpool.Pool, logger *slog.Logger) *Repo {
+	return &Repo{db: db, log: logging.OrDiscard(logger)}
 }
 
+// SaveCheck keeps in.CreatedAt when set, so replayed checks count towards
+// stats at the time they were made.
 func (r *Repo) SaveCheck(ctx context.Context, in domain.LocationCheck) (int64, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return 0, cerr
+	}
 	const q = `
-insert into location_checks (user_id, latitude, longitude, has_danger)
-values ($1, $2, $3, $4)
+insert into location_checks (tenant_id, user_id, latitude, longitude, has_danger, client_id, created_at)
+values ($1, $2, $3, $4, $5, nullif($6, ''), coalesce($7, now()))
 returning id;
 `
+	var createdAt *time.Time
+	if !in.CreatedAt.IsZero() {
+		createdAt = &in.CreatedAt
+	}
 	var id int64
-	if err := r.db.QueryRow(ctx, q, in.UserID, in.Latitude, in.Longitude, in.HasDanger).Scan(&id); err != nil {
-		return 0, common.NewError(common.CodeIternalErr, err.Error())
+	if err := r.db.QueryRow(ctx, q, tenantID, in.UserID, in.Latitude, in.Longitude, in.HasDanger, in.ClientID, createdAt).Scan(&id); err != nil {
+		return 0, common.Internal(ctx, r.log, "Repo.SaveCheck", err)
 	}
 	return id, nil
 }
 
 func (r *Repo) CountUniqueUsersSince(ctx context.Context, since time.Time) (int64, *common.Error) {
-	const q = `select count(distinct user_id) from location_checks where created_at >= $1;`
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return 0, cerr
+	}
+	const q = `select count(distinct user_id) from location_checks where tenant_id = $1 and created_at >= $2;`
 	var count int64
-	if err := r.db.QueryRow(ctx, q, since).Scan(&count); err != nil {
-		return 0, common.NewError(common.CodeIternalErr, err.Error())
+	if err := r.db.QueryRow(ctx, q, tenantID, since).Scan(&count); err != nil {
+		return 0, common.Internal(ctx, r.log, "Repo.CountUniqueUsersSince", err)
 	}
 	return count, nil
 }
@@ -0,0 +1,135 @@
+package location
+
+import (
+	domain "RedColarTest/internal/locations/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SpooledCheck is a location check that could not be saved, kept with its
+// tenant until it is replayed.
+type SpooledCheck struct {
+	ID       string               `json:"-"`
+	TenantID int64                `json:"tenant_id"`
+	Check    domain.LocationCheck `json:"check"`
+}
+
+// CheckSpool buffers location checks while Postgres is unavailable. Peek
+// returns the oldest entries without removing them; Remove drops them once
+// they are saved.
+type CheckSpool interface {
+	Push(ctx context.Context, in SpooledCheck) error
+	Peek(ctx context.Context, limit int) ([]SpooledCheck, error)
+	Remove(ctx context.Context, ids ...string) error
+	Len(ctx context.Context) (int64, error)
+}
+
+const spoolStream = "location_checks:spool"
+
+// RedisSpool keeps the spool in a Redis stream shared by all instances.
+// Once maxLen entries are buffered the oldest are trimmed.
+type RedisSpool struct {
+	client *redis.Client
+	maxLen int64
+}
+
+func NewRedisSpool(client *redis.Client, maxLen int64) *RedisSpool {
+	return &RedisSpool{client: client, maxLen: maxLen}
+}
+
+func (s *RedisSpool) Push(ctx context.Context, in SpooledCheck) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: spoolStream,
+		MaxLen: s.maxLen,
+		Approx: true,
+		Values: map[string]any{"check": b},
+	}).Err()
+}
+
+func (s *RedisSpool) Peek(ctx context.Context, limit int) ([]SpooledCheck, error) {
+	msgs, err := s.client.XRangeN(ctx, spoolStream, "-", "+", int64(limit)).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]SpooledCheck, 0, len(msgs))
+	for _, m := range msgs {
+		raw, _ := m.Values["check"].(string)
+		var it SpooledCheck
+		if err := json.Unmarshal([]byte(raw), &it); err != nil {
+			return nil, fmt.Errorf("decode spooled check %s: %w", m.ID, err)
+		}
+		it.ID = m.ID
+		out = append(out, it)
+	}
+	return out, nil
+}
+
+func (s *RedisSpool) Remove(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.client.XDel(ctx, spoolStream, ids...).Err()
+}
+
+func (s *RedisSpool) Len(ctx context.Context) (int64, error) {
+	return s.client.XLen(ctx, spoolStream).Result()
+}
+
+// MemorySpool is a CheckSpool kept in process memory. It backs tests.
+type MemorySpool struct {
+	mu     sync.Mutex
+	nextID int64
+	items  []SpooledCheck
+}
+
+func NewMemorySpool() *MemorySpool {
+	return &MemorySpool{}
+}
+
+func (s *MemorySpool) Push(_ context.Context, in SpooledCheck) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	in.ID = strconv.FormatInt(s.nextID, 10)
+	s.items = append(s.items, in)
+	return nil
+}
+
+func (s *MemorySpool) Peek(_ context.Context, limit int) ([]SpooledCheck, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := min(limit, len(s.items))
+	return append([]SpooledCheck(nil), s.items[:n]...), nil
+}
+
+func (s *MemorySpool) Remove(_ context.Context, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	drop := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		drop[id] = struct{}{}
+	}
+	kept := s.items[:0]
+	for _, it := range s.items {
+		if _, ok := drop[it.ID]; !ok {
+			kept = append(kept, it)
+		}
+	}
+	s.items = kept
+	return nil
+}
+
+func (s *MemorySpool) Len(context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.items)), nil
+}
@@ -0,0 +1,145 @@
+package location
+
+import (
+	"RedColarTest/internal/cache"
+	"RedColarTest/internal/common"
+	domain "RedColarTest/internal/locations/domain"
+	locationrepo "RedColarTest/internal/locations/repository"
+	"RedColarTest/internal/logging"
+	"RedColarTest/internal/metrics"
+	tenant "RedColarTest/internal/tenants/domain"
+	"context"
+	"time"
+)
+
+// Degraded configures how location checks keep working while Postgres is
+// unavailable. The zero value fails them instead.
+type Degraded struct {
+	// Snapshots answer checks when active incidents cannot be loaded.
+	Snapshots *Snapshots
+	// MaxAge is the oldest snapshot still served.
+	MaxAge time.Duration
+	// Spool buffers checks that could not be saved for ReplaySpool.
+	Spool locationrepo.CheckSpool
+	// Locker elects the instance that replays the spool; nil lets every
+	// instance replay.
+	Locker cache.Locker
+}
+
+const (
+	spoolLockKey   = "lock:location_checks:spool"
+	spoolLockTTL   = time.Minute
+	spoolBatchSize = 100
+)
+
+// fallback returns the snapshot to serve when loading active incidents failed
+// with err.
+func (s *Service) fallback(ctx context.Context, err *common.Error) (Snapshot, bool) {
+	tenantID, ok := tenant.ID(ctx)
+	if s.degraded.Snapshots == nil || !ok || !unavailable(err) {
+		return Snapshot{}, false
+	}
+	snap, ok := s.degraded.Snapshots.Get(tenantID)
+	if !ok || time.Since(snap.TakenAt) > s.degraded.MaxAge {
+		return Snapshot{}, false
+	}
+	s.log.WarnContext(ctx, "serving location check from incident snapshot",
+		"taken_at", snap.TakenAt, "err", err)
+	s.metrics.ObserveDegradedCheck()
+	return snap, true
+}
+
+// spool buffers a check whose save failed with err and reports whether it did.
+func (s *Service) spool(ctx context.Context, check domain.LocationCheck, err *common.Error) bool {
+	tenantID, ok := tenant.ID(ctx)
+	if s.degraded.Spool == nil || !ok || !unavailable(err) {
+		return false
+	}
+	check.CreatedAt = time.Now().UTC()
+	in := locationrepo.SpooledCheck{TenantID: tenantID, Check: check}
+	if pushErr := s.degraded.Spool.Push(ctx, in); pushErr != nil {
+		s.log.ErrorContext(ctx, "spool location check failed", "err", pushErr)
+		return false
+	}
+	s.log.WarnContext(ctx, "location check spooled", "user_id", check.UserID, "err", err)
+	s.metrics.ObserveSpool(metrics.SpoolSpooled)
+	return true
+}
+
+// ReplaySpool saves spooled checks in order until the spool is empty or a
+// save fails. Only one instance replays at a time.
+func (s *Service) ReplaySpool(ctx context.Context) (int, *common.Error) {
+	spool := s.degraded.Spool
+	if spool == nil || s.repo == nil {
+		return 0, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, spoolLockTTL)
+	defer cancel()
+	if s.degraded.Locker != nil {
+		unlock, ok, err := s.degraded.Locker.TryLock(ctx, spoolLockKey, spoolLockTTL)
+		if err != nil {
+			return 0, common.NewError(common.CodeUnavailable, err.Error())
+		}
+		if !ok {
+			return 0, nil
+		}
+		defer unlock()
+	}
+
+	replayed := 0
+	for {
+		batch, err := spool.Peek(ctx, spoolBatchSize)
+		if err != nil {
+			return replayed, common.NewError(common.CodeUnavailable, err.Error())
+		}
+		if len(batch) == 0 {
+			return replayed, nil
+		}
+		for _, it := range batch {
+			_, saveErr := s.repo.SaveCheck(tenant.WithID(ctx, it.TenantID), it.Check)
+			switch {
+			case saveErr == nil:
+				replayed++
+				s.metrics.ObserveSpool(metrics.SpoolReplayed)
+			case unavailable(saveErr):
+				return replayed, saveErr
+			default:
+				// Retrying will not help; keep it from blocking the rest.
+				s.log.ErrorContext(ctx, "drop spooled location check", "id", it.ID, "tenant_id", it.TenantID, "err", saveErr)
+				s.metrics.ObserveSpool(metrics.SpoolDropped)
+			}
+			if err := spool.Remove(ctx, it.ID); err != nil {
+				return replayed, common.NewError(common.CodeUnavailable, err.Error())
+			}
+		}
+	}
+}
+
+func (s *Service) RunSpoolReplay(ctx context.Context, interval time.Duration) {
+	if s.degraded.Spool == nil || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			runCtx := logging.WithRequestID(ctx, logging.NewRequestID())
+			n, err := s.ReplaySpool(runCtx)
+			if n > 0 {
+				s.log.InfoContext(runCtx, "spooled location checks replayed", "count", n)
+			}
+			if err != nil {
+				s.log.WarnContext(runCtx, "replay location check spool failed", "replayed", n, "err", err)
+			}
+		}
+	}
+}
+
+// unavailable reports errors worth waiting out: Postgres could not be reached.
+// Anything else, e.g. a constraint violation, will fail again on retry.
+func unavailable(err *common.Error) bool {
+	return err.Code == common.CodeUnavailable
+}
@@ -0,0 +1,130 @@
+package location
+
+import (
+	incdomain "RedColarTest/internal/incident/domain"
+	"RedColarTest/internal/logging"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Snapshot is the last active incident set a tenant was served.
+type Snapshot struct {
+	Incidents []incdomain.Incident `json:"incidents"`
+	TakenAt   time.Time            `json:"taken_at"`
+}
+
+// Snapshots keeps a last known good Snapshot per tenant so location checks
+// can still be answered while the incident store is unreachable. With a path
+// they are also written to disk and survive a restart.
+type Snapshots struct {
+	path string
+	log  *slog.Logger
+
+	mu       sync.Mutex
+	byTenant map[int64]Snapshot
+	dirty    bool
+}
+
+// NewSnapshots loads the snapshots saved at path, if any. An empty path keeps
+// them in memory only.
+func NewSnapshots(path string, logger *slog.Logger) *Snapshots {
+	s := &Snapshots{path: path, log: logging.OrDiscard(logger), byTenant: make(map[int64]Snapshot)}
+	if path == "" {
+		return s
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		s.log.Warn("read incident snapshots failed", "path", path, "err", err)
+	default:
+		if err := json.Unmarshal(data, &s.byTenant); err != nil {
+			s.log.Warn("decode incident snapshots failed", "path", path, "err", err)
+			s.byTenant = make(map[int64]Snapshot)
+		}
+	}
+	return s
+}
+
+func (s *Snapshots) Put(tenantID int64, incidents []incdomain.Incident, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byTenant[tenantID] = Snapshot{Incidents: incidents, TakenAt: at}
+	s.dirty = true
+}
+
+func (s *Snapshots) Get(tenantID int64) (Snapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap, ok := s.byTenant[tenantID]
+	return snap, ok
+}
+
+// Flush writes changed snapshots to disk, replacing the file atomically.
+func (s *Snapshots) Flush() error {
+	if s.path == "" {
+		return nil
+	}
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(s.byTenant)
+	s.dirty = false
+	s.mu.Unlock()
+	if err == nil {
+		err = s.write(data)
+	}
+	if err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func (s *Snapshots) write(data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Run flushes snapshots every interval and once more when ctx is done.
+func (s *Snapshots) Run(ctx context.Context, interval time.Duration) {
+	if s.path == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Flush(); err != nil {
+				s.log.Error("write incident snapshots failed", "path", s.path, "err", err)
+			}
+			return
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				s.log.ErrorContext(ctx, "write incident snapshots failed", "path", s.path, "err", err)
+			}
+		}
+	}
+}
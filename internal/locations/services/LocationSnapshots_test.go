@@ -0,0 +1,27 @@
+package location
+
+import (
+	incdomain "RedColarTest/internal/incident/domain"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotsSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshots.json")
+	taken := time.Now().UTC().Truncate(time.Second)
+
+	s := NewSnapshots(path, nil)
+	s.Put(1, []incdomain.Incident{{ID: 7, Title: "Flood", TenantID: 1}}, taken)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, ok := NewSnapshots(path, nil).Get(1)
+	if !ok || len(snap.Incidents) != 1 || snap.Incidents[0].ID != 7 || !snap.TakenAt.Equal(taken) {
+		t.Fatalf("reloaded snapshot = %+v, %v", snap, ok)
+	}
+	if _, ok := NewSnapshots(path, nil).Get(2); ok {
+		t.Fatal("snapshot found for an unknown tenant")
+	}
+}
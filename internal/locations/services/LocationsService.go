@@ -1,47 +1,64 @@
 package location
 
 import (
+	"RedColarTest/internal/cache"
+	clients "RedColarTest/internal/clients/domain"
 	"RedColarTest/internal/common"
+	"RedColarTest/internal/geo"
 	incdomain "RedColarTest/internal/incident/domain"
 	increpo "RedColarTest/internal/incident/repository"
 	domain "RedColarTest/internal/locations/domain"
 	locationrepo "RedColarTest/internal/locations/repository"
+	"RedColarTest/internal/logging"
+	"RedColarTest/internal/metrics"
+	tenant "RedColarTest/internal/tenants/domain"
 	"RedColarTest/internal/webhook"
 	"context"
-	"encoding/json"
 	"errors"
-	"math"
+	"log/slog"
 	"sort"
 	"time"
-
-	"github.com/redis/go-redis/v9"
 )
 
+const activeIncidentsCache = "active_incidents"
+
 type Service struct {
-	repo      *locationrepo.Repo
-	incRepo   increpo.IncidentRepository
-	cache     *redis.Client
-	cacheTTL  time.Duration
-	webhookQ  *webhook.Queue
-	cacheKey  string
-	cacheLive bool
+	repo       locationrepo.LocationRepository
+	incRepo    increpo.IncidentRepository
+	cache      cache.Cache
+	versions   cache.Versions
+	refresher  *cache.Refresher
+	webhookQ   webhook.Enqueuer
+	cacheScope string
+	cacheLive  bool
+	degraded   Degraded
+	metrics    *metrics.Metrics
+	log        *slog.Logger
 }
 
 func NewLocationService(
-	repo *locationrepo.Repo,
+	repo locationrepo.LocationRepository,
 	incRepo increpo.IncidentRepository,
-	cache *redis.Client,
-	cacheTTL time.Duration,
-	webhookQ *webhook.Queue,
+	c cache.Cache,
+	versions cache.Versions,
+	cacheOpts cache.RefreshOptions,
+	degraded Degraded,
+	webhookQ webhook.Enqueuer,
+	m *metrics.Metrics,
+	logger *slog.Logger,
 ) *Service {
 	return &Service{
-		repo:      repo,
-		incRepo:   incRepo,
-		cache:     cache,
-		cacheTTL:  cacheTTL,
-		webhookQ:  webhookQ,
-		cacheKey:  "cache:active_incidents",
-		cacheLive: cache != nil && cacheTTL > 0,
+		repo:       repo,
+		incRepo:    incRepo,
+		cache:      c,
+		versions:   versions,
+		refresher:  cache.NewRefresher(c, cacheOpts),
+		webhookQ:   webhookQ,
+		cacheScope: activeIncidentsCache,
+		cacheLive:  c != nil && versions != nil && cacheOpts.TTL > 0,
+		degraded:   degraded,
+		metrics:    m,
+		log:        logging.OrDiscard(logger),
 	}
 }
 
@@ -53,14 +70,21 @@ func (s *Service) CheckLocation(ctx context.Context, userID string, lat, lon flo
 		return domain.CheckResult{}, err
 	}
 
+	var res domain.CheckResult
 	incidents, err := s.getActiveIncidents(ctx)
 	if err != nil {
-		return domain.CheckResult{}, err
+		snap, ok := s.fallback(ctx, err)
+		if !ok {
+			return domain.CheckResult{}, err
+		}
+		incidents = snap.Incidents
+		res.Stale = true
+		res.SnapshotAge = time.Since(snap.TakenAt)
 	}
 
 	matches := make([]domain.IncidentDistance, 0)
 	for _, inc := range incidents {
-		dist := haversineMeters(lat, lon, inc.Latitude, inc.Longitude)
+		dist := geo.DistanceMeters(lat, lon, inc.Latitude, inc.Longitude)
 		if dist <= float64(inc.DangerRadiusM) {
 			matches = append(matches, domain.IncidentDistance{
 				IncidentID:    inc.ID,
@@ -77,11 +101,11 @@ func (s *Service) CheckLocation(ctx context.Context, userID string, lat, lon flo
 	sort.Slice(matches, func(i, j int) bool {
 		return matches[i].DistanceM < matches[j].DistanceM
 	})
+	s.metrics.ObserveCheck(len(matches) > 0)
 
-	return domain.CheckResult{
-		Dangerous: len(matches) > 0,
-		Incidents: matches,
-	}, nil
+	res.Dangerous = len(matches) > 0
+	res.Incidents = matches
+	return res, nil
 }
 
 func (s *Service) RecordCheck(ctx context.Context, userID string, lat, lon float64, incidents []domain.IncidentDistance) (int64, *common.Error) {
@@ -91,13 +115,15 @@ func (s *Service) RecordCheck(ctx context.Context, userID string, lat, lon float
 	if err := validateLocationInput(userID, lat, lon); err != nil {
 		return 0, err
 	}
-	checkID, err := s.repo.SaveCheck(ctx, domain.LocationCheck{
+	check := domain.LocationCheck{
 		UserID:    userID,
+		ClientID:  clients.ClientID(ctx),
 		Latitude:  lat,
 		Longitude: lon,
 		HasDanger: len(incidents) > 0,
-	})
-	if err != nil {
+	}
+	checkID, err := s.repo.SaveCheck(ctx, check)
+	if err != nil && !s.spool(ctx, check, err) {
 		return 0, err
 	}
 
@@ -110,7 +136,9 @@ func (s *Service) RecordCheck(ctx context.Context, userID string, lat, lon float
 			Incidents: mapWebhookIncidents(incidents),
 			CreatedAt: time.Now().UTC(),
 		}
-		_ = s.webhookQ.Enqueue(ctx, payload)
+		if err := s.webhookQ.Enqueue(ctx, payload); err != nil {
+			s.log.ErrorContext(ctx, "enqueue location webhook failed", "check_id", checkID, "err", err)
+		}
 	}
 
 	return checkID, nil
@@ -127,35 +155,89 @@ func (s *Service) Stats(ctx context.Context, windowMinutes int) (int64, *common.
 	return s.repo.CountUniqueUsersSince(ctx, since)
 }
 
+// listActive reads the active incidents from Postgres and keeps them as the
+// tenant's last known good snapshot, stamped with the time of the read.
+func (s *Service) listActive(ctx context.Context) ([]incdomain.Incident, *common.Error) {
+	readAt := time.Now()
+	incidents, err := s.incRepo.ListActive(ctx)
+	if tenantID, ok := tenant.ID(ctx); ok && err == nil && s.degraded.Snapshots != nil {
+		s.degraded.Snapshots.Put(tenantID, incidents, readAt)
+	}
+	return incidents, err
+}
+
 func (s *Service) getActiveIncidents(ctx context.Context) ([]incdomain.Incident, *common.Error) {
 	if s.incRepo == nil {
 		return nil, common.NewError(common.CodeIternalErr, "incident repo is not initialized")
 	}
 	if !s.cacheLive {
-		return s.incRepo.ListActive(ctx)
+		return s.listActive(ctx)
 	}
 
-	raw, err := s.cache.Get(ctx, s.cacheKey).Result()
-	if err == nil {
-		var cached []incdomain.Incident
-		if err := json.Unmarshal([]byte(raw), &cached); err == nil {
-			return cached, nil
+	key, err := s.currentKey(ctx)
+	if err != nil {
+		s.log.WarnContext(ctx, "read incidents cache version failed", "err", err)
+		s.metrics.ObserveCache(activeIncidentsCache, metrics.CacheError)
+		return s.listActive(ctx)
+	}
+	incidents, outcome, err := cache.Fetch(ctx, s.refresher, key, func(ctx context.Context) ([]incdomain.Incident, error) {
+		incidents, err := s.listActive(ctx)
+		if err != nil {
+			return nil, err
 		}
-		_ = s.cache.Del(ctx, s.cacheKey).Err()
-	} else if !errors.Is(err, redis.Nil) {
-		_ = s.cache.Del(ctx, s.cacheKey).Err()
+		return incidents, nil
+	})
+	switch outcome {
+	case cache.OutcomeHit:
+		s.metrics.ObserveCache(activeIncidentsCache, metrics.CacheHit)
+	case cache.OutcomeStale:
+		s.metrics.ObserveCache(activeIncidentsCache, metrics.CacheStale)
+	case cache.OutcomeMiss:
+		s.metrics.ObserveCache(activeIncidentsCache, metrics.CacheMiss)
+	default:
+		s.metrics.ObserveCache(activeIncidentsCache, metrics.CacheError)
 	}
-
-	incidents, repoErr := s.incRepo.ListActive(ctx)
-	if repoErr != nil {
-		return nil, repoErr
+	if err != nil {
+		var cerr *common.Error
+		if errors.As(err, &cerr) {
+			return nil, cerr
+		}
+		return nil, common.Internal(ctx, s.log, "Service.getActiveIncidents", err)
 	}
+	return incidents, nil
+}
+
+// Wait blocks until background cache refreshes finish or ctx is done.
+func (s *Service) Wait(ctx context.Context) error {
+	return s.refresher.Wait(ctx)
+}
 
-	if payload, err := json.Marshal(incidents); err == nil {
-		_ = s.cache.Set(ctx, s.cacheKey, payload, s.cacheTTL).Err()
+// CacheWarm reports whether the active incidents of the default tenant are
+// cached; it backs the readiness probe, which has no tenant of its own.
+// enabled is false when caching is off.
+func (s *Service) CacheWarm(ctx context.Context) (warm, enabled bool, err error) {
+	if !s.cacheLive {
+		return false, false, nil
+	}
+	ctx = tenant.WithID(ctx, tenant.DefaultID)
+	key, err := s.currentKey(ctx)
+	if err != nil {
+		return false, true, err
 	}
+	warm, err = s.cache.Exists(ctx, key)
+	return warm, true, err
+}
 
-	return incidents, nil
+func (s *Service) currentKey(ctx context.Context) (string, error) {
+	scope, cerr := tenant.ScopedKey(ctx, s.cacheScope)
+	if cerr != nil {
+		return "", cerr
+	}
+	v, err := s.versions.Current(ctx, scope)
+	if err != nil {
+		return "", err
+	}
+	return cache.VersionedKey(scope, v), nil
 }
 
 func mapWebhookIncidents(incidents []domain.IncidentDistance) []webhook.PayloadIncident {
@@ -186,15 +268,3 @@ func validateLocationInput(userID string, lat, lon float64) *common.Error {
 	}
 	return nil
 }
-
-func haversineMeters(lat1, lon1, lat2, lon2 float64) float64 {
-	const earthRadius = 6371000.0
-	rad := func(d float64) float64 { return d * math.Pi / 180 }
-	dLat := rad(lat2 - lat1)
-	dLon := rad(lon2 - lon1)
-
-	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
-		math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-	return earthRadius * c
-}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	pgAcquired = prometheus.NewDesc(namespace+"_pgxpool_acquired_conns", "Connections currently in use.", nil, nil)
+	pgIdle     = prometheus.NewDesc(namespace+"_pgxpool_idle_conns", "Idle connections.", nil, nil)
+	pgTotal    = prometheus.NewDesc(namespace+"_pgxpool_total_conns", "Open connections.", nil, nil)
+	pgMax      = prometheus.NewDesc(namespace+"_pgxpool_max_conns", "Pool size limit.", nil, nil)
+	pgAcquires = prometheus.NewDesc(namespace+"_pgxpool_acquires_total", "Successful acquires.", nil, nil)
+	pgEmpty    = prometheus.NewDesc(namespace+"_pgxpool_empty_acquires_total", "Acquires that had to wait for a connection.", nil, nil)
+	pgWait     = prometheus.NewDesc(namespace+"_pgxpool_acquire_wait_seconds_total", "Time spent waiting for a connection.", nil, nil)
+)
+
+type pgxPoolCollector struct {
+	pool *pgxpool.Pool
+}
+
+// PgxPool exports pgxpool.Stat.
+func PgxPool(pool *pgxpool.Pool) prometheus.Collector {
+	return pgxPoolCollector{pool: pool}
+}
+
+func (c pgxPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{pgAcquired, pgIdle, pgTotal, pgMax, pgAcquires, pgEmpty, pgWait} {
+		ch <- d
+	}
+}
+
+func (c pgxPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(pgAcquired, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(pgIdle, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(pgTotal, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(pgMax, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(pgAcquires, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(pgEmpty, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(pgWait, prometheus.CounterValue, s.AcquireDuration().Seconds())
+}
+
+var (
+	redisHits     = prometheus.NewDesc(namespace+"_redis_pool_hits_total", "Times a free connection was found in the pool.", nil, nil)
+	redisMisses   = prometheus.NewDesc(namespace+"_redis_pool_misses_total", "Times a free connection was not found in the pool.", nil, nil)
+	redisTimeouts = prometheus.NewDesc(namespace+"_redis_pool_timeouts_total", "Times a wait for a connection timed out.", nil, nil)
+	redisTotal    = prometheus.NewDesc(namespace+"_redis_pool_total_conns", "Open connections.", nil, nil)
+	redisIdle     = prometheus.NewDesc(namespace+"_redis_pool_idle_conns", "Idle connections.", nil, nil)
+	redisStale    = prometheus.NewDesc(namespace+"_redis_pool_stale_conns_total", "Stale connections removed from the pool.", nil, nil)
+)
+
+type redisPoolCollector struct {
+	client *redis.Client
+}
+
+// RedisPool exports redis.PoolStats.
+func RedisPool(client *redis.Client) prometheus.Collector {
+	return redisPoolCollector{client: client}
+}
+
+func (c redisPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{redisHits, redisMisses, redisTimeouts, redisTotal, redisIdle, redisStale} {
+		ch <- d
+	}
+}
+
+func (c redisPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(redisHits, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(redisMisses, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(redisTimeouts, prometheus.CounterValue, float64(s.Timeouts))
+	ch <- prometheus.MustNewConstMetric(redisTotal, prometheus.GaugeValue, float64(s.TotalConns))
+	ch <- prometheus.MustNewConstMetric(redisIdle, prometheus.GaugeValue, float64(s.IdleConns))
+	ch <- prometheus.MustNewConstMetric(redisStale, prometheus.CounterValue, float64(s.StaleConns))
+}
+
+var (
+	queueDepth = prometheus.NewDesc(namespace+"_webhook_queue_depth", "Webhook jobs waiting in the queue.", nil, nil)
+	spoolDepth = prometheus.NewDesc(namespace+"_location_check_spool_depth", "Location checks waiting to be replayed.", nil, nil)
+)
+
+type depthCollector struct {
+	desc  *prometheus.Desc
+	depth func(ctx context.Context) (int64, error)
+}
+
+// QueueDepth reads the webhook backlog at scrape time. Scrapes during a Redis
+// outage simply omit the metric.
+func QueueDepth(depth func(ctx context.Context) (int64, error)) prometheus.Collector {
+	return depthCollector{desc: queueDepth, depth: depth}
+}
+
+// SpoolDepth reads the location check spool backlog at scrape time.
+func SpoolDepth(depth func(ctx context.Context) (int64, error)) prometheus.Collector {
+	return depthCollector{desc: spoolDepth, depth: depth}
+}
+
+func (c depthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c depthCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	n, err := c.depth(ctx)
+	if err != nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n))
+}
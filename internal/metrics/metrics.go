@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "redcolar"
+
+// Metrics holds the service collectors. All methods are safe on a nil
+// receiver so components can run without metrics.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpDuration     *prometheus.HistogramVec
+	checks           prometheus.Counter
+	dangerousChecks  prometheus.Counter
+	degradedChecks   prometheus.Counter
+	spooledChecks    *prometheus.CounterVec
+	cacheRequests    *prometheus.CounterVec
+	invalidations    *prometheus.CounterVec
+	deliveryAttempts *prometheus.CounterVec
+	deliveryFailures *prometheus.CounterVec
+	deliveryDuration *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		checks: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "location_checks_total",
+			Help:      "Location checks served.",
+		}),
+		dangerousChecks: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "location_checks_dangerous_total",
+			Help:      "Location checks that matched at least one incident.",
+		}),
+		degradedChecks: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "location_checks_degraded_total",
+			Help:      "Location checks answered from the last known good snapshot.",
+		}),
+		spooledChecks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "location_check_spool_total",
+			Help:      "Location checks written to the spool and replayed from it (spooled, replayed, dropped).",
+		}, []string{"result"}),
+		cacheRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_requests_total",
+			Help:      "Cache lookups by cache and result (hit, stale, miss, error).",
+		}, []string{"cache", "result"}),
+		invalidations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_invalidations_total",
+			Help:      "Cache version changes by how this instance learned of them (publish, message, poll).",
+		}, []string{"source"}),
+		deliveryAttempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhook_delivery_attempts_total",
+			Help:      "Webhook delivery attempts by destination.",
+		}, []string{"destination"}),
+		deliveryFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhook_delivery_failures_total",
+			Help:      "Failed webhook delivery attempts by destination.",
+		}, []string{"destination"}),
+		deliveryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "webhook_delivery_duration_seconds",
+			Help:      "Webhook delivery latency by destination.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"destination"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpDuration,
+		m.checks,
+		m.dangerousChecks,
+		m.degradedChecks,
+		m.spooledChecks,
+		m.cacheRequests,
+		m.invalidations,
+		m.deliveryAttempts,
+		m.deliveryFailures,
+		m.deliveryDuration,
+	)
+	return m
+}
+
+// Register adds extra collectors, e.g. pool stats.
+func (m *Metrics) Register(cs ...prometheus.Collector) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(cs...)
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) ObserveHTTP(method, route string, status int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.httpDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(d.Seconds())
+}
+
+func (m *Metrics) ObserveCheck(dangerous bool) {
+	if m == nil {
+		return
+	}
+	m.checks.Inc()
+	if dangerous {
+		m.dangerousChecks.Inc()
+	}
+}
+
+func (m *Metrics) ObserveDegradedCheck() {
+	if m == nil {
+		return
+	}
+	m.degradedChecks.Inc()
+}
+
+const (
+	SpoolSpooled  = "spooled"
+	SpoolReplayed = "replayed"
+	SpoolDropped  = "dropped"
+)
+
+func (m *Metrics) ObserveSpool(result string) {
+	if m == nil {
+		return
+	}
+	m.spooledChecks.WithLabelValues(result).Inc()
+}
+
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheStale = "stale"
+	CacheError = "error"
+)
+
+func (m *Metrics) ObserveCache(cache, result string) {
+	if m == nil {
+		return
+	}
+	m.cacheRequests.WithLabelValues(cache, result).Inc()
+}
+
+const (
+	InvalidationPublish = "publish"
+	InvalidationMessage = "message"
+	InvalidationPoll    = "poll"
+)
+
+func (m *Metrics) ObserveInvalidation(source string) {
+	if m == nil {
+		return
+	}
+	m.invalidations.WithLabelValues(source).Inc()
+}
+
+func (m *Metrics) ObserveDelivery(destination string, d time.Duration, failed bool) {
+	if m == nil {
+		return
+	}
+	m.deliveryAttempts.WithLabelValues(destination).Inc()
+	m.deliveryDuration.WithLabelValues(destination).Observe(d.Seconds())
+	if failed {
+		m.deliveryFailures.WithLabelValues(destination).Inc()
+	}
+}
@@ -1,22 +1,41 @@
 package middleware
 
 import (
+	"RedColarTest/internal/apikeys/domain"
+	"context"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+const PrincipalKey = "principal"
+
 type APIKeyValidator interface {
-	IsValid(rawKey string) bool
+	Validate(ctx context.Context, rawKey string) (domain.Principal, bool)
 }
 
 type StaticAPIKeyValidator struct {
 	Expected string
 }
 
-func (v StaticAPIKeyValidator) IsValid(rawKey string) bool {
-	return rawKey != "" && rawKey == v.Expected
+func (v StaticAPIKeyValidator) Validate(_ context.Context, rawKey string) (domain.Principal, bool) {
+	if rawKey == "" || rawKey != v.Expected {
+		return domain.Principal{}, false
+	}
+	return domain.Principal{Name: "operator", Roles: []string{domain.RoleAdmin}, Platform: true}, true
+}
+
+// MultiAPIKeyValidator accepts a key if any of its validators does.
+type MultiAPIKeyValidator []APIKeyValidator
+
+func (m MultiAPIKeyValidator) Validate(ctx context.Context, rawKey string) (domain.Principal, bool) {
+	for _, v := range m {
+		if p, ok := v.Validate(ctx, rawKey); ok {
+			return p, true
+		}
+	}
+	return domain.Principal{}, false
 }
 
 func APIKeyAuth(validator APIKeyValidator) gin.HandlerFunc {
@@ -29,11 +48,22 @@ func APIKeyAuth(validator APIKeyValidator) gin.HandlerFunc {
 			}
 		}
 
-		if !validator.IsValid(key) {
+		principal, ok := validator.Validate(c.Request.Context(), key)
+		if !ok {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 			return
 		}
 
+		c.Set(PrincipalKey, principal)
 		c.Next()
 	}
 }
+
+func GetPrincipal(c *gin.Context) (domain.Principal, bool) {
+	v, ok := c.Get(PrincipalKey)
+	if !ok {
+		return domain.Principal{}, false
+	}
+	p, ok := v.(domain.Principal)
+	return p, ok
+}
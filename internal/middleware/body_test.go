@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestByBodyFieldRejectsLargeBodies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	key := ByBodyField("user_id")
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"user_id":"`+strings.Repeat("x", maxBodyBytes)+`"}`))
+	if got := key(c); got != "" || !c.IsAborted() || rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized body: key = %q, aborted = %v, status = %d", got, c.IsAborted(), rec.Code)
+	}
+}
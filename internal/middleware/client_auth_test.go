@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	clients "RedColarTest/internal/clients/domain"
+	"RedColarTest/internal/tenants/domain"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+type stubClients map[string]int64
+
+func (s stubClients) LookupClient(_ context.Context, clientID string) (string, int64, bool) {
+	tenantID, ok := s[clientID]
+	return "secret-" + clientID, tenantID, ok
+}
+
+type stubReplay map[string]bool
+
+func (s stubReplay) SetNX(_ context.Context, key string, _ interface{}, _ time.Duration) *redis.BoolCmd {
+	fresh := !s[key]
+	s[key] = true
+	return redis.NewBoolResult(fresh, nil)
+}
+
+func sign(secret string, ts int64, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s", ts, body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestClientAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const body = `{"user_id":"u1","latitude":55.75,"longitude":37.61}`
+	now := time.Now().Unix()
+	store := stubClients{"app": 2}
+
+	type request struct {
+		clientID  string
+		ts        string
+		signature string
+		tenant    int64
+		body      string
+	}
+	signed := func(clientID string, ts int64) request {
+		return request{clientID: clientID, ts: strconv.FormatInt(ts, 10), signature: sign("secret-"+clientID, ts, body), body: body}
+	}
+
+	tests := []struct {
+		name       string
+		mode       clients.AuthMode
+		requests   []request
+		wantStatus int
+		wantTenant int64
+		wantClient string
+	}{
+		{name: "off ignores signature", mode: clients.AuthOff, requests: []request{{clientID: "app", ts: "x", body: body}}, wantStatus: http.StatusOK},
+		{name: "optional passes unsigned", mode: clients.AuthOptional, requests: []request{{body: body}}, wantStatus: http.StatusOK},
+		{name: "required rejects unsigned", mode: clients.AuthRequired, requests: []request{{body: body}}, wantStatus: http.StatusUnauthorized},
+		{name: "valid signature", mode: clients.AuthRequired, requests: []request{signed("app", now)}, wantStatus: http.StatusOK, wantTenant: 2, wantClient: "app"},
+		{name: "optional checks signed", mode: clients.AuthOptional, requests: []request{{clientID: "app", ts: strconv.FormatInt(now, 10), signature: sign("wrong", now, body), body: body}}, wantStatus: http.StatusUnauthorized},
+		{name: "tampered body", mode: clients.AuthRequired, requests: []request{func() request {
+			r := signed("app", now)
+			r.body = strings.Replace(body, "u1", "u2", 1)
+			return r
+		}()}, wantStatus: http.StatusUnauthorized},
+		{name: "signature over another timestamp", mode: clients.AuthRequired, requests: []request{func() request {
+			r := signed("app", now)
+			r.ts = strconv.FormatInt(now-1, 10)
+			return r
+		}()}, wantStatus: http.StatusUnauthorized},
+		{name: "invalid timestamp", mode: clients.AuthRequired, requests: []request{{clientID: "app", ts: "soon", signature: sign("secret-app", now, body), body: body}}, wantStatus: http.StatusUnauthorized},
+		{name: "within skew", mode: clients.AuthRequired, requests: []request{signed("app", now-4*60)}, wantStatus: http.StatusOK, wantTenant: 2, wantClient: "app"},
+		{name: "too old", mode: clients.AuthRequired, requests: []request{signed("app", now-6*60)}, wantStatus: http.StatusUnauthorized},
+		{name: "too far ahead", mode: clients.AuthRequired, requests: []request{signed("app", now+6*60)}, wantStatus: http.StatusUnauthorized},
+		{name: "unknown client", mode: clients.AuthRequired, requests: []request{signed("ghost", now)}, wantStatus: http.StatusUnauthorized},
+		{name: "replayed request", mode: clients.AuthRequired, requests: []request{signed("app", now), signed("app", now)}, wantStatus: http.StatusUnauthorized},
+		{name: "same tenant header", mode: clients.AuthRequired, requests: []request{func() request {
+			r := signed("app", now)
+			r.tenant = 2
+			return r
+		}()}, wantStatus: http.StatusOK, wantTenant: 2, wantClient: "app"},
+		{name: "another tenant header", mode: clients.AuthRequired, requests: []request{func() request {
+			r := signed("app", now)
+			r.tenant = 3
+			return r
+		}()}, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotTenant int64
+			var gotClient string
+			r := gin.New()
+			r.POST("/",
+				func(c *gin.Context) {
+					if id, err := strconv.ParseInt(c.GetHeader(TenantHeader), 10, 64); err == nil {
+						c.Request = c.Request.WithContext(domain.WithID(c.Request.Context(), id))
+					}
+				},
+				ClientAuth(store, stubReplay{}, ClientAuthConfig{Mode: tt.mode, MaxSkew: 5 * time.Minute}),
+				func(c *gin.Context) {
+					gotTenant, _ = domain.ID(c.Request.Context())
+					gotClient = clients.ClientID(c.Request.Context())
+					c.Status(http.StatusOK)
+				},
+			)
+
+			var rec *httptest.ResponseRecorder
+			for _, in := range tt.requests {
+				req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(in.body))
+				if in.clientID != "" {
+					req.Header.Set(ClientIDHeader, in.clientID)
+					req.Header.Set(ClientTimestampHeader, in.ts)
+					req.Header.Set(ClientSignatureHeader, in.signature)
+				}
+				if in.tenant != 0 {
+					req.Header.Set(TenantHeader, strconv.FormatInt(in.tenant, 10))
+				}
+				rec = httptest.NewRecorder()
+				r.ServeHTTP(rec, req)
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK && (gotTenant != tt.wantTenant || gotClient != tt.wantClient) {
+				t.Fatalf("tenant = %d, client = %q; want %d, %q", gotTenant, gotClient, tt.wantTenant, tt.wantClient)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	clients "RedColarTest/internal/clients/domain"
+	"RedColarTest/internal/logging"
+	"RedColarTest/internal/tenants/domain"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKeyLen = 255
+	// pendingTTL bounds how long a key stays locked if the instance dies
+	// while handling the first request.
+	pendingTTL = 30 * time.Second
+)
+
+type idempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Done        bool   `json:"done"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+type Idempotency struct {
+	redis *redis.Client
+	ttl   time.Duration
+	log   *slog.Logger
+}
+
+func NewIdempotency(redisClient *redis.Client, ttl time.Duration, logger *slog.Logger) *Idempotency {
+	return &Idempotency{redis: redisClient, ttl: ttl, log: logging.OrDiscard(logger)}
+}
+
+// Handler replays the stored response when a request is repeated with the
+// same Idempotency-Key. Keys are scoped to the route, tenant and caller: the
+// principal, the signed client or, for anonymous requests, the client IP.
+// Reusing a key with a different body gives 422, repeating it while the first
+// request is still running gives 409. 5xx responses are not stored so the
+// client can retry. Redis errors let the request through.
+func (i *Idempotency) Handler(route string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(IdempotencyKeyHeader))
+		if key == "" || i == nil || i.redis == nil || i.ttl <= 0 {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		body, ok := readBody(c)
+		if !ok {
+			return
+		}
+
+		ctx := c.Request.Context()
+		tenantID, ok := domain.ID(ctx)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "request has no tenant"})
+			return
+		}
+		redisKey := idempotencyKey(c, route, tenantID, key)
+		fingerprint := requestFingerprint(c.Request, body)
+
+		pending, _ := json.Marshal(idempotencyRecord{Fingerprint: fingerprint})
+		acquired, err := i.redis.SetNX(ctx, redisKey, pending, pendingTTL).Result()
+		if err != nil {
+			i.log.WarnContext(ctx, "idempotency check failed", "route", route, "err", err)
+			c.Next()
+			return
+		}
+		if !acquired {
+			i.replay(c, route, redisKey, fingerprint)
+			return
+		}
+
+		rec := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		c.Next()
+
+		status := rec.Status()
+		if status >= http.StatusInternalServerError {
+			_ = i.redis.Del(ctx, redisKey).Err()
+			return
+		}
+		done, _ := json.Marshal(idempotencyRecord{
+			Fingerprint: fingerprint,
+			Done:        true,
+			Status:      status,
+			ContentType: rec.Header().Get("Content-Type"),
+			Body:        rec.buf.Bytes(),
+		})
+		if err := i.redis.Set(ctx, redisKey, done, i.ttl).Err(); err != nil {
+			i.log.WarnContext(ctx, "idempotency store failed", "route", route, "err", err)
+		}
+	}
+}
+
+func (i *Idempotency) replay(c *gin.Context, route, redisKey, fingerprint string) {
+	ctx := c.Request.Context()
+	raw, err := i.redis.Get(ctx, redisKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// The first request failed and released the key in between.
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "request with this Idempotency-Key is in progress"})
+		return
+	}
+	if err != nil {
+		i.log.WarnContext(ctx, "idempotency check failed", "route", route, "err", err)
+		c.Next()
+		return
+	}
+
+	var stored idempotencyRecord
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "corrupted idempotency record"})
+		return
+	}
+	switch {
+	case stored.Fingerprint != fingerprint:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+	case !stored.Done:
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "request with this Idempotency-Key is in progress"})
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(stored.Status, stored.ContentType, stored.Body)
+		c.Abort()
+	}
+}
+
+func idempotencyKey(c *gin.Context, route string, tenantID int64, key string) string {
+	ctx := c.Request.Context()
+	owner := ByPrincipal(c)
+	if owner == "" {
+		owner = clients.ClientID(ctx)
+	}
+	if owner == "" {
+		owner = "ip:" + c.ClientIP()
+	}
+	return "idempotency:" + route + ":" + strconv.FormatInt(tenantID, 10) + ":" + owner + ":" + key
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type bodyRecorder struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.buf.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.buf.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"RedColarTest/internal/tenants/domain"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIdempotencyKeySeparatesAnonymousCallers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keyFor := func(remote string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+		c.Request.RemoteAddr = remote
+		return idempotencyKey(c, "location_check", domain.DefaultID, "k1")
+	}
+
+	if keyFor("10.0.0.1:1000") == keyFor("10.0.0.2:1000") {
+		t.Fatal("anonymous callers from different IPs share an idempotency key")
+	}
+	if keyFor("10.0.0.1:1000") != keyFor("10.0.0.1:2000") {
+		t.Fatal("the same caller got different idempotency keys")
+	}
+}
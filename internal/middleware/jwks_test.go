@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func b64(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func writeJWKS(t *testing.T, path string, keys map[string]crypto.PublicKey) {
+	t.Helper()
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	for kid, key := range keys {
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, jwk{Kty: "RSA", Kid: kid, Use: "sig", N: b64(k.N), E: b64(big.NewInt(int64(k.E)))})
+		case *ecdsa.PublicKey:
+			set.Keys = append(set.Keys, jwk{Kty: "EC", Kid: kid, Crv: "P-256", X: b64(k.X), Y: b64(k.Y)})
+		}
+	}
+	raw, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key crypto.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, claims)
+	tok.Header["kid"] = kid
+	raw, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestJWTVerifierWithFileJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, map[string]crypto.PublicKey{"rsa-1": &rsaKey.PublicKey, "ec-1": &ecKey.PublicKey})
+
+	keys := NewJWKS(path, time.Hour)
+	if err := keys.Load(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	v := NewJWTVerifier(keys, JWTConfig{Issuer: "sso", RoleMapping: map[string]string{"ops": "dispatcher"}})
+
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   "sso",
+			"sub":   "u-1",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"roles": []string{"ops", "unknown"},
+			"scope": "apikeys:admin webhooks:admin",
+		}
+	}
+
+	p, err := v.Verify(t.Context(), signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims()))
+	if err != nil {
+		t.Fatalf("RS256: %v", err)
+	}
+	if p.Subject != "u-1" || len(p.Roles) != 1 || p.Roles[0] != "dispatcher" || len(p.Scopes) != 0 {
+		t.Fatalf("RS256 principal = %+v", p)
+	}
+	if _, err := v.Verify(t.Context(), signToken(t, jwt.SigningMethodES256, "ec-1", ecKey, claims())); err != nil {
+		t.Fatalf("ES256: %v", err)
+	}
+
+	if _, err := v.Verify(t.Context(), signToken(t, jwt.SigningMethodRS256, "ec-1", rsaKey, claims())); err == nil {
+		t.Fatal("token signed with the wrong key was accepted")
+	}
+	wrongIssuer := claims()
+	wrongIssuer["iss"] = "other"
+	if _, err := v.Verify(t.Context(), signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, wrongIssuer)); err == nil {
+		t.Fatal("token from another issuer was accepted")
+	}
+	expired := claims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	if _, err := v.Verify(t.Context(), signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, expired)); err == nil {
+		t.Fatal("expired token was accepted")
+	}
+}
+
+func TestJWKSUnknownKidRespectsMinRefresh(t *testing.T) {
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, map[string]crypto.PublicKey{"old": &oldKey.PublicKey})
+
+	keys := NewJWKS(path, time.Hour)
+	if err := keys.Load(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	writeJWKS(t, path, map[string]crypto.PublicKey{"old": &oldKey.PublicKey, "new": &newKey.PublicKey})
+
+	if _, err := keys.Key(t.Context(), "new"); err != errUnknownKey {
+		t.Fatalf("unknown kid right after a load: err = %v, want errUnknownKey", err)
+	}
+
+	keys.mu.Lock()
+	keys.attemptedAt = time.Now().Add(-2 * jwksMinRefresh)
+	keys.mu.Unlock()
+	if _, err := keys.Key(t.Context(), "new"); err != nil {
+		t.Fatalf("rotated key after min refresh: %v", err)
+	}
+}
+
+func TestJWKSFailedLoadIsRateLimited(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	keys := NewJWKS(srv.URL, time.Hour)
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			keys.Key(t.Context(), "kid")
+		}()
+	}
+	wg.Wait()
+	if got := hits.Load(); got != 1 {
+		t.Fatalf("concurrent lookups fetched %d times, want 1", got)
+	}
+
+	if _, err := keys.Key(t.Context(), "kid"); err != errUnknownKey {
+		t.Fatalf("lookup after a failed load: err = %v, want errUnknownKey", err)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Fatalf("failed load was retried before min refresh: %d fetches", got)
+	}
+}
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"RedColarTest/internal/apikeys/domain"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type JWTConfig struct {
+	Issuer   string
+	Audience string
+	// RolesClaim is a dotted path to the roles array in the token, e.g.
+	// "roles" or "realm_access.roles".
+	RolesClaim string
+	// RoleMapping translates claim values (e.g. SSO group names) to service
+	// roles. Values without a mapping are ignored; an empty mapping passes
+	// claim values through unchanged.
+	RoleMapping map[string]string
+	// TenantClaim is a dotted path to the tenant slug or id. Tokens without
+	// it are served the default tenant.
+	TenantClaim string
+}
+
+type JWTVerifier struct {
+	keys *JWKS
+	cfg  JWTConfig
+}
+
+func NewJWTVerifier(keys *JWKS, cfg JWTConfig) *JWTVerifier {
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = "roles"
+	}
+	if cfg.TenantClaim == "" {
+		cfg.TenantClaim = "tenant"
+	}
+	return &JWTVerifier{keys: keys, cfg: cfg}
+}
+
+func (v *JWTVerifier) Verify(ctx context.Context, raw string) (domain.Principal, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+	}
+	if v.cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(v.cfg.Issuer))
+	}
+	if v.cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(v.cfg.Audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.Key(ctx, kid)
+	}, opts...)
+	if err != nil {
+		return domain.Principal{}, err
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return domain.Principal{}, errors.New("token has no subject")
+	}
+	name := subject
+	if n, ok := claims["preferred_username"].(string); ok && n != "" {
+		name = n
+	}
+	return domain.Principal{
+		Subject:   subject,
+		Name:      name,
+		Roles:     v.mapRoles(lookupStrings(claims, v.cfg.RolesClaim)),
+		TenantRef: lookupString(claims, v.cfg.TenantClaim),
+	}, nil
+}
+
+func (v *JWTVerifier) mapRoles(values []string) []string {
+	if len(v.cfg.RoleMapping) == 0 {
+		return values
+	}
+	roles := make([]string, 0, len(values))
+	for _, value := range values {
+		if role, ok := v.cfg.RoleMapping[value]; ok && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+func lookupString(claims jwt.MapClaims, path string) string {
+	switch val := lookupClaim(claims, path).(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatInt(int64(val), 10)
+	}
+	return ""
+}
+
+func lookupStrings(claims jwt.MapClaims, path string) []string {
+	switch val := lookupClaim(claims, path).(type) {
+	case string:
+		return strings.Fields(val)
+	case []any:
+		out := make([]string, 0, len(val))
+		for _, it := range val {
+			if s, ok := it.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func lookupClaim(claims jwt.MapClaims, path string) any {
+	var cur any = map[string]any(claims)
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[part]
+	}
+	return cur
+}
+
+// OperatorAuth accepts either an API key (x-api-key or "Authorization:
+// ApiKey ...") or, when verifier is set, a JWT in "Authorization: Bearer ...".
+func OperatorAuth(validator APIKeyValidator, verifier *JWTVerifier) gin.HandlerFunc {
+	apiKey := APIKeyAuth(validator)
+	return func(c *gin.Context) {
+		auth := strings.TrimSpace(c.GetHeader("authorization"))
+		if !strings.HasPrefix(strings.ToLower(auth), "bearer ") {
+			apiKey(c)
+			return
+		}
+		if verifier == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		principal, err := verifier.Verify(c.Request.Context(), strings.TrimSpace(auth[7:]))
+		if err != nil {
+			c.Header("WWW-Authenticate", fmt.Sprintf("Bearer error=%q", "invalid_token"))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		c.Set(PrincipalKey, principal)
+		c.Next()
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"RedColarTest/internal/metrics"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var knownMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// Metrics records request latency by route template, so path parameters do
+// not blow up label cardinality. Unknown paths share one "unmatched" route and
+// non-standard methods one "OTHER" method.
+func Metrics(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		if !slices.Contains(knownMethods, method) {
+			method = "OTHER"
+		}
+		m.ObserveHTTP(method, route, c.Writer.Status(), time.Since(start))
+	}
+}
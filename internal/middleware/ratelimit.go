@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"RedColarTest/internal/logging"
+	"RedColarTest/internal/ratelimit"
+	"RedColarTest/internal/tenants/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// RateRule applies a limit to the key returned by Key; requests for which Key
+// returns "" are not counted.
+type RateRule struct {
+	Name  string
+	Limit ratelimit.Limit
+	Key   func(c *gin.Context) string
+}
+
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is when the bucket is full again; RetryAfter is when the next
+	// request will be allowed (zero if allowed now).
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// tokenBucket refills Limit tokens per window and keeps its state in a hash so
+// all instances share it. Redis time is used to avoid clock skew between
+// instances.
+var tokenBucket = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = limit
+  ts = now
+end
+
+local rate = limit / window
+tokens = math.min(limit, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], window)
+
+return {allowed, math.floor(tokens), math.ceil((limit - tokens) / rate), retry}
+`)
+
+type RateLimiter struct {
+	redis  *redis.Client
+	log    *slog.Logger
+	prefix string
+}
+
+func NewRateLimiter(redisClient *redis.Client, logger *slog.Logger) *RateLimiter {
+	return &RateLimiter{redis: redisClient, log: logging.OrDiscard(logger), prefix: "ratelimit"}
+}
+
+func (l *RateLimiter) Allow(ctx context.Context, key string, limit ratelimit.Limit) (RateLimitResult, error) {
+	res, err := tokenBucket.Run(ctx, l.redis, []string{l.prefix + ":" + key}, limit.Limit, limit.Window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	if len(res) != 4 {
+		return RateLimitResult{}, fmt.Errorf("rate limit: unexpected reply %v", res)
+	}
+	return RateLimitResult{
+		Allowed:    res[0] == 1,
+		Limit:      limit.Limit,
+		Remaining:  int(res[1]),
+		Reset:      time.Duration(res[2]) * time.Millisecond,
+		RetryAfter: time.Duration(res[3]) * time.Millisecond,
+	}, nil
+}
+
+// Limit enforces every enabled rule for the route. The RateLimit-* headers
+// describe the most constrained rule. Redis errors let the request through.
+func (l *RateLimiter) Limit(route string, rules ...RateRule) gin.HandlerFunc {
+	active := make([]RateRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Limit.Enabled() {
+			active = append(active, rule)
+		}
+	}
+	return func(c *gin.Context) {
+		if l == nil || l.redis == nil || len(active) == 0 {
+			c.Next()
+			return
+		}
+
+		var (
+			tightest RateLimitResult
+			denied   bool
+			seen     bool
+		)
+		for _, rule := range active {
+			key := rule.Key(c)
+			if c.IsAborted() {
+				return
+			}
+			if key == "" {
+				continue
+			}
+			res, err := l.Allow(c.Request.Context(), route+":"+rule.Name+":"+key, rule.Limit)
+			if err != nil {
+				l.log.WarnContext(c.Request.Context(), "rate limit check failed", "route", route, "rule", rule.Name, "err", err)
+				continue
+			}
+			if !res.Allowed {
+				if !denied || res.RetryAfter > tightest.RetryAfter {
+					tightest = res
+				}
+				denied = true
+				continue
+			}
+			if !denied && (!seen || res.Remaining < tightest.Remaining) {
+				tightest = res
+			}
+			seen = true
+		}
+		if !seen && !denied {
+			c.Next()
+			return
+		}
+
+		setRateLimitHeaders(c, tightest)
+		if denied {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(tightest.RetryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func setRateLimitHeaders(c *gin.Context, res RateLimitResult) {
+	c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(max(res.Remaining, 0)))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+func ByClientIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// ByBodyField keys by a top-level string field of the JSON body, scoped to
+// the request tenant. The body is restored for the handler; bodies over
+// maxBodyBytes are rejected with 413.
+func ByBodyField(field string) func(c *gin.Context) string {
+	return func(c *gin.Context) string {
+		body, ok := readBody(c)
+		if !ok || body == nil {
+			return ""
+		}
+
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(body, &fields) != nil {
+			return ""
+		}
+		var value string
+		if json.Unmarshal(fields[field], &value) != nil || value == "" {
+			return ""
+		}
+		tenantID, ok := domain.ID(c.Request.Context())
+		if !ok {
+			return ""
+		}
+		return strconv.FormatInt(tenantID, 10) + ":" + value
+	}
+}
+
+// ByPrincipal keys by the authenticated API key or token subject.
+func ByPrincipal(c *gin.Context) string {
+	p, ok := GetPrincipal(c)
+	switch {
+	case !ok:
+		return ""
+	case p.KeyID != 0:
+		return "key:" + strconv.FormatInt(p.KeyID, 10)
+	case p.Subject != "":
+		return "sub:" + p.Subject
+	default:
+		return "name:" + p.Name
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	tenant "RedColarTest/internal/tenants/domain"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok || !principal.Allowed(permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden", "required_permission": permission})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Audit logs every state-changing operator request together with the
+// principal that made it.
+func Audit(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			return
+		}
+		principal, _ := GetPrincipal(c)
+		ctx := c.Request.Context()
+		tenantID, _ := tenant.ID(ctx)
+		logger.InfoContext(ctx, "audit",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"principal", principal.Name,
+			"key_id", principal.KeyID,
+			"subject", principal.Subject,
+			"roles", principal.Roles,
+			"tenant_id", tenantID,
+		)
+	}
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"RedColarTest/internal/tenants/domain"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const TenantHeader = "X-Tenant-ID"
+
+type TenantResolver interface {
+	// ResolveTenant maps a slug or numeric id to a tenant id; ok is false for
+	// unknown and inactive tenants.
+	ResolveTenant(ctx context.Context, ref string) (id int64, ok bool)
+}
+
+// PublicTenant scopes unauthenticated requests to the tenant named in
+// X-Tenant-ID, or to the default tenant when the header is absent.
+func PublicTenant(resolver TenantResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := domain.DefaultID
+		if ref := strings.TrimSpace(c.GetHeader(TenantHeader)); ref != "" {
+			resolved, ok := resolver.ResolveTenant(c.Request.Context(), ref)
+			if !ok {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "unknown tenant"})
+				return
+			}
+			id = resolved
+		}
+		setTenant(c, id)
+		c.Next()
+	}
+}
+
+// OperatorTenant scopes authenticated requests. Tenant-bound principals are
+// always served their own tenant and get 403 when X-Tenant-ID names another
+// one; platform principals may pick any tenant with the header.
+func OperatorTenant(resolver TenantResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, _ := GetPrincipal(c)
+		ctx := c.Request.Context()
+
+		var requested int64
+		if ref := strings.TrimSpace(c.GetHeader(TenantHeader)); ref != "" {
+			id, ok := resolver.ResolveTenant(ctx, ref)
+			if !ok {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "unknown tenant"})
+				return
+			}
+			requested = id
+		}
+
+		bound := p.TenantID
+		if bound == 0 && p.TenantRef != "" {
+			id, ok := resolver.ResolveTenant(ctx, p.TenantRef)
+			if !ok {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "tenant is not active"})
+				return
+			}
+			bound = id
+		}
+		if bound == 0 && !p.Platform {
+			bound = domain.DefaultID
+		}
+
+		id := bound
+		switch {
+		case bound == 0 && requested != 0:
+			id = requested
+		case bound == 0:
+			id = domain.DefaultID
+		case requested != 0 && requested != bound:
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden", "tenant_id": bound})
+			return
+		}
+		if bound != 0 {
+			if _, ok := resolver.ResolveTenant(ctx, strconv.FormatInt(bound, 10)); !ok {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "tenant is not active"})
+				return
+			}
+		}
+
+		setTenant(c, id)
+		c.Next()
+	}
+}
+
+// RequirePlatform limits a route to principals not bound to a tenant.
+func RequirePlatform() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := GetPrincipal(c)
+		if !ok || !p.Platform {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func setTenant(c *gin.Context, id int64) {
+	c.Request = c.Request.WithContext(domain.WithID(c.Request.Context(), id))
+}
@@ -0,0 +1,241 @@
+package migrate
+
+import (
+	"RedColarTest/internal/logging"
+	"RedColarTest/migrations"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"strings"
+	"time"
+
+	gomigrate "github.com/golang-migrate/migrate/v4"
+	pgxmigrate "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// lockName keys the advisory lock that serialises migrations across
+// replicas and the migrate subcommand.
+const lockName = "RedColarTest:migrate"
+
+// Status describes the schema version recorded in schema_migrations against
+// the migrations embedded in the binary.
+type Status struct {
+	Version uint
+	Applied bool
+	Dirty   bool
+	Latest  uint
+	Pending []uint
+}
+
+// Migrator applies the embedded migrations to one database. Every change
+// runs under a session-level advisory lock, so concurrent callers wait for
+// each other instead of racing on schema_migrations.
+type Migrator struct {
+	db       *sql.DB
+	m        *gomigrate.Migrate
+	versions []uint
+	log      *slog.Logger
+}
+
+func New(databaseURL string, logger *slog.Logger) (*Migrator, error) {
+	versions, err := embeddedVersions()
+	if err != nil {
+		return nil, err
+	}
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("open embedded migrations: %w", err)
+	}
+	db, err := sql.Open("pgx", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	driver, err := pgxmigrate.WithInstance(db, &pgxmigrate.Config{})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("init migrate driver: %w", err)
+	}
+	m, err := gomigrate.NewWithInstance("iofs", src, "pgx", driver)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("init migrate: %w", err)
+	}
+	log := logging.OrDiscard(logger)
+	m.Log = migrateLogger{log}
+	// Our own lock already serialises callers; the driver lock below it never
+	// waits, so its timeout only matters if someone bypasses this package.
+	m.LockTimeout = time.Minute
+	return &Migrator{db: db, m: m, versions: versions, log: log}, nil
+}
+
+// Up applies all pending migrations. It is a no-op when the schema is
+// current.
+func (mg *Migrator) Up(ctx context.Context) error {
+	return mg.locked(ctx, mg.m.Up)
+}
+
+// Down reverts the last steps migrations, or all of them when steps is 0.
+func (mg *Migrator) Down(ctx context.Context, steps int) error {
+	if steps < 0 {
+		return fmt.Errorf("steps must not be negative")
+	}
+	if steps == 0 {
+		return mg.locked(ctx, mg.m.Down)
+	}
+	return mg.locked(ctx, func() error { return mg.m.Steps(-steps) })
+}
+
+// Force records version as applied and clears the dirty flag without running
+// any SQL. Use it after fixing a failed migration by hand; -1 means no
+// version.
+func (mg *Migrator) Force(ctx context.Context, version int) error {
+	if version < -1 {
+		return fmt.Errorf("invalid version %d", version)
+	}
+	if version > 0 && !mg.known(uint(version)) {
+		return fmt.Errorf("version %d is not among the embedded migrations", version)
+	}
+	return mg.locked(ctx, func() error { return mg.m.Force(version) })
+}
+
+func (mg *Migrator) Status(ctx context.Context) (Status, error) {
+	st := Status{}
+	if n := len(mg.versions); n > 0 {
+		st.Latest = mg.versions[n-1]
+	}
+	if err := mg.db.PingContext(ctx); err != nil {
+		return st, fmt.Errorf("connect to database: %w", err)
+	}
+	version, dirty, err := mg.m.Version()
+	switch {
+	case errors.Is(err, gomigrate.ErrNilVersion):
+	case err != nil:
+		return st, fmt.Errorf("read schema version: %w", err)
+	default:
+		st.Version, st.Applied, st.Dirty = version, true, dirty
+	}
+	for _, v := range mg.versions {
+		if !st.Applied || v > st.Version {
+			st.Pending = append(st.Pending, v)
+		}
+	}
+	return st, nil
+}
+
+func (mg *Migrator) Close() error {
+	srcErr, dbErr := mg.m.Close()
+	return errors.Join(srcErr, dbErr)
+}
+
+// locked runs fn while holding the migration advisory lock on a dedicated
+// connection. Waiting for the lock honours ctx; once fn has started,
+// cancelling ctx stops it after the migration in progress.
+func (mg *Migrator) locked(ctx context.Context, fn func() error) error {
+	conn, err := mg.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	if _, err := conn.ExecContext(ctx, "select pg_advisory_lock(hashtext($1))", lockName); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		mg.log.Info("acquired migration lock", "waited_ms", waited.Milliseconds())
+	}
+	defer func() {
+		unlockCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if _, err := conn.ExecContext(unlockCtx, "select pg_advisory_unlock(hashtext($1))", lockName); err != nil {
+			mg.log.Warn("release migration lock failed", "err", err)
+		}
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			select {
+			case mg.m.GracefulStop <- true:
+			default:
+			}
+		case <-done:
+		}
+	}()
+
+	if err := fn(); err != nil && !errors.Is(err, gomigrate.ErrNoChange) {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("migration interrupted: %w", err)
+	}
+	return nil
+}
+
+func (mg *Migrator) known(version uint) bool {
+	for _, v := range mg.versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// embeddedVersions lists the migration versions in order and checks that
+// each one has a non-empty down migration.
+func embeddedVersions() ([]uint, error) {
+	entries, err := fs.ReadDir(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read embedded migrations: %w", err)
+	}
+	ups := make(map[uint]bool)
+	downs := make(map[uint]bool)
+	var versions []uint
+	for _, e := range entries {
+		name := e.Name()
+		var version uint
+		if _, err := fmt.Sscanf(name, "%d_", &version); err != nil {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			ups[version] = true
+			versions = append(versions, version)
+		case strings.HasSuffix(name, ".down.sql"):
+			body, err := fs.ReadFile(migrations.FS, name)
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", name, err)
+			}
+			if strings.TrimSpace(string(body)) == "" {
+				return nil, fmt.Errorf("down migration %s is empty", name)
+			}
+			downs[version] = true
+		}
+	}
+	for _, v := range versions {
+		if !downs[v] {
+			return nil, fmt.Errorf("migration %d has no down migration", v)
+		}
+	}
+	for v := range downs {
+		if !ups[v] {
+			return nil, fmt.Errorf("migration %d has no up migration", v)
+		}
+	}
+	return versions, nil
+}
+
+type migrateLogger struct{ log *slog.Logger }
+
+func (l migrateLogger) Printf(format string, v ...any) {
+	l.log.Info(strings.TrimSpace(fmt.Sprintf(format, v...)), "component", "migrate")
+}
+
+func (l migrateLogger) Verbose() bool { return false }
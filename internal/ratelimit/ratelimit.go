@@ -0,0 +1,40 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Limit requests per Window with bursts up to Limit. The zero
+// value disables the rule.
+type Limit struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Parse reads "<limit>/<window>", e.g. "60/1m". Empty and "0" mean no limit.
+func Parse(raw string) (Limit, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "0" {
+		return Limit{}, nil
+	}
+	count, window, ok := strings.Cut(raw, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q: expected <limit>/<window>", raw)
+	}
+	limit, err := strconv.Atoi(count)
+	if err != nil || limit < 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: invalid limit", raw)
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: invalid window", raw)
+	}
+	return Limit{Limit: limit, Window: d}, nil
+}
+
+func (l Limit) Enabled() bool {
+	return l.Limit > 0 && l.Window > 0
+}
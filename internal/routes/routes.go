@@ -1,39 +1,136 @@
 package routes
 
 import (
+	perms "RedColarTest/internal/apikeys/domain"
+	apikeys "RedColarTest/internal/apikeys/handlers"
+	clients "RedColarTest/internal/clients/handlers"
 	"RedColarTest/internal/incident/handlers"
 	location "RedColarTest/internal/locations/handlers"
+	"RedColarTest/internal/logging"
+	"RedColarTest/internal/metrics"
 	"RedColarTest/internal/middleware"
+	"RedColarTest/internal/ratelimit"
 	system "RedColarTest/internal/system/handlers"
+	tenants "RedColarTest/internal/tenants/handlers"
+	webhook "RedColarTest/internal/webhook/handlers"
+	"fmt"
+	"log/slog"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 type RouterDeps struct {
 	IncidentHandler *handlers.IncidentHandler
 	LocationHandler *location.Handler
 	HealthHandler   *system.Handler
-	OperatorKey     string
+	WebhookHandler  *webhook.SubscriptionHandler
+	APIKeyHandler   *apikeys.APIKeyHandler
+	TenantHandler   *tenants.TenantHandler
+	ClientHandler   *clients.ClientHandler
+	APIKeyValidator middleware.APIKeyValidator
+	JWTVerifier     *middleware.JWTVerifier
+	TenantResolver  middleware.TenantResolver
+	ClientAuth      gin.HandlerFunc
+	RateLimiter     *middleware.RateLimiter
+	RateLimits      RateLimits
+	TrustedProxies  []string
+	Idempotency     *middleware.Idempotency
+	Logger          *slog.Logger
+	Metrics         *metrics.Metrics
+	// ServiceName is the server name on HTTP spans.
+	ServiceName string
 }
 
-func NewRouter(d RouterDeps) *gin.Engine {
+type RateLimits struct {
+	CheckPerIP   ratelimit.Limit
+	CheckPerUser ratelimit.Limit
+	// Operator applies per API key or token subject on all operator routes.
+	Operator ratelimit.Limit
+}
+
+func NewRouter(d RouterDeps) (*gin.Engine, error) {
+	logger := logging.OrDiscard(d.Logger)
 	r := gin.New()
-	r.Use(gin.Recovery(), gin.Logger())
+	r.Use(
+		otelgin.Middleware(d.ServiceName, otelgin.WithFilter(func(req *http.Request) bool {
+			switch req.URL.Path {
+			case "/livez", "/readyz":
+				return false
+			}
+			return true
+		})),
+		middleware.RequestID(),
+		middleware.Recovery(logger),
+		middleware.AccessLog(logger),
+		middleware.Metrics(d.Metrics),
+	)
+	// gin trusts every proxy by default; with none configured X-Forwarded-For
+	// is ignored and the client IP is the peer address.
+	if err := r.SetTrustedProxies(d.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("trusted proxies: %w", err)
+	}
+	r.GET("/livez", d.HealthHandler.Livez)
+	r.GET("/readyz", d.HealthHandler.Readyz)
 
 	v1 := r.Group("/api/v1")
 
-	v1.POST("/location/check", d.LocationHandler.LocationCheckHandler)
-	v1.GET("/system/health", d.HealthHandler.Health)
+	// The IP limit runs first so unknown X-Tenant-ID values cannot be used to
+	// hammer the tenant lookup.
+	checkIPLimit := d.RateLimiter.Limit("location_check",
+		middleware.RateRule{Name: "ip", Limit: d.RateLimits.CheckPerIP, Key: middleware.ByClientIP},
+	)
+	checkUserLimit := d.RateLimiter.Limit("location_check",
+		middleware.RateRule{Name: "user", Limit: d.RateLimits.CheckPerUser, Key: middleware.ByBodyField("user_id")},
+	)
+	v1.POST("/location/check", checkIPLimit, middleware.PublicTenant(d.TenantResolver), d.ClientAuth, checkUserLimit,
+		d.Idempotency.Handler("location_check"), d.LocationHandler.LocationCheckHandler)
+	// Kept for existing monitors; same as /readyz.
+	v1.GET("/system/health", d.HealthHandler.Readyz)
 
 	op := v1.Group("")
-	op.Use(middleware.APIKeyAuth(middleware.StaticAPIKeyValidator{Expected: d.OperatorKey}))
+	op.Use(
+		middleware.OperatorAuth(d.APIKeyValidator, d.JWTVerifier),
+		middleware.OperatorTenant(d.TenantResolver),
+		d.RateLimiter.Limit("operator", middleware.RateRule{Name: "principal", Limit: d.RateLimits.Operator, Key: middleware.ByPrincipal}),
+		middleware.Audit(logger),
+	)
+
+	// viewer: read + stats; dispatcher: + incident writes; admin: + webhooks and keys.
+	read := middleware.RequirePermission(perms.ScopeIncidentsRead)
+	write := middleware.RequirePermission(perms.ScopeIncidentsWrite)
+	stats := middleware.RequirePermission(perms.ScopeStatsRead)
+	webhooks := middleware.RequirePermission(perms.ScopeWebhooksAdmin)
+	keys := middleware.RequirePermission(perms.ScopeAPIKeysAdmin)
+
+	op.POST("/incidents", write, d.Idempotency.Handler("incidents"), d.IncidentHandler.Create)
+	op.GET("/incidents", read, d.IncidentHandler.List)
+	op.GET("/incidents/stats", stats, d.LocationHandler.StatsHandler)
+	op.GET("/incidents/:id", read, d.IncidentHandler.GetByID)
+	op.PUT("/incidents/:id", write, d.IncidentHandler.Update)
+	op.DELETE("/incidents/:id", write, d.IncidentHandler.Deactivate)
+
+	op.POST("/webhooks/subscriptions", webhooks, d.WebhookHandler.Create)
+	op.GET("/webhooks/subscriptions", webhooks, d.WebhookHandler.List)
+	op.GET("/webhooks/subscriptions/:id", webhooks, d.WebhookHandler.GetByID)
+	op.PUT("/webhooks/subscriptions/:id", webhooks, d.WebhookHandler.Update)
+	op.DELETE("/webhooks/subscriptions/:id", webhooks, d.WebhookHandler.Delete)
+	op.POST("/webhooks/subscriptions/:id/ping", webhooks, d.WebhookHandler.Ping)
+
+	op.POST("/apikeys", keys, d.APIKeyHandler.Mint)
+	op.GET("/apikeys", keys, d.APIKeyHandler.List)
+	op.DELETE("/apikeys/:id", keys, d.APIKeyHandler.Revoke)
+	op.POST("/apikeys/:id/rotate", keys, d.APIKeyHandler.Rotate)
+
+	op.POST("/clients", keys, d.ClientHandler.Register)
+	op.GET("/clients", keys, d.ClientHandler.List)
+	op.DELETE("/clients/:id", keys, d.ClientHandler.Revoke)
 
-	op.POST("/incidents", d.IncidentHandler.Create)
-	op.GET("/incidents", d.IncidentHandler.List)
-	op.GET("/incidents/stats", d.LocationHandler.StatsHandler)
-	op.GET("/incidents/:id", d.IncidentHandler.GetByID)
-	op.PUT("/incidents/:id", d.IncidentHandler.Update)
-	op.DELETE("/incidents/:id", d.IncidentHandler.Deactivate)
+	platform := middleware.RequirePlatform()
+	op.POST("/tenants", platform, d.TenantHandler.Create)
+	op.GET("/tenants", platform, d.TenantHandler.List)
+	op.PUT("/tenants/:id", platform, d.TenantHandler.Update)
 
-	return r
+	return r, nil
 }
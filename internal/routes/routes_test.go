@@ -0,0 +1,256 @@
+package routes_test
+
+import (
+	apikeys "RedColarTest/internal/apikeys/domain"
+	"RedColarTest/internal/apitest"
+	"RedColarTest/internal/cache"
+	"RedColarTest/internal/common"
+	"RedColarTest/internal/incident/domain"
+	"RedColarTest/internal/middleware"
+	"RedColarTest/internal/routes"
+	tenant "RedColarTest/internal/tenants/domain"
+	"RedColarTest/internal/webhook"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+type listResponse struct {
+	Items []domain.Incident `json:"items"`
+	Total int64             `json:"total"`
+}
+
+type checkResponse struct {
+	Dangerous bool `json:"dangerous"`
+	Incidents []struct {
+		ID int64 `json:"id"`
+	} `json:"incidents"`
+	Stale       bool   `json:"stale"`
+	SnapshotAge *int64 `json:"snapshot_age_seconds"`
+}
+
+func createIncident(t *testing.T, h *apitest.Harness, title string, lat, lon float64, radius int, headers ...string) domain.Incident {
+	t.Helper()
+	var out domain.Incident
+	h.Operator(http.MethodPost, "/api/v1/incidents", map[string]any{
+		"title": title, "latitude": lat, "longitude": lon, "danger_radius_m": radius,
+	}, headers...).Expect(http.StatusCreated).JSON(&out)
+	return out
+}
+
+func TestIncidentCRUD(t *testing.T) {
+	h := apitest.New(t, apitest.Options{})
+
+	created := createIncident(t, h, "Fire", 55.75, 37.61, 300)
+	if created.ID == 0 || !created.IsActive || created.DangerRadiusM != 300 {
+		t.Fatalf("unexpected incident %+v", created)
+	}
+
+	var got domain.Incident
+	h.Operator(http.MethodGet, fmt.Sprintf("/api/v1/incidents/%d", created.ID), nil).Expect(http.StatusOK).JSON(&got)
+	if got.Title != "Fire" {
+		t.Fatalf("title = %q", got.Title)
+	}
+
+	var updated domain.Incident
+	h.Operator(http.MethodPut, fmt.Sprintf("/api/v1/incidents/%d", created.ID), map[string]any{
+		"title": "Fire (contained)", "latitude": 55.75, "longitude": 37.61, "danger_radius_m": 100, "is_active": true,
+	}).Expect(http.StatusOK).JSON(&updated)
+	if updated.Title != "Fire (contained)" || updated.DangerRadiusM != 100 {
+		t.Fatalf("unexpected update %+v", updated)
+	}
+
+	h.Operator(http.MethodPut, fmt.Sprintf("/api/v1/incidents/%d", created.ID), map[string]any{
+		"title": "Fire", "latitude": 55.75, "longitude": 37.61, "danger_radius_m": 100, "is_active": true,
+		"expires_at": "2000-01-01T00:00:00Z",
+	}).Expect(http.StatusBadRequest)
+
+	h.Operator(http.MethodDelete, fmt.Sprintf("/api/v1/incidents/%d", created.ID), nil).Expect(http.StatusOK)
+	h.Operator(http.MethodDelete, fmt.Sprintf("/api/v1/incidents/%d", created.ID), nil).Expect(http.StatusNotFound)
+
+	var active, all listResponse
+	h.Operator(http.MethodGet, "/api/v1/incidents?only_active=true", nil).Expect(http.StatusOK).JSON(&active)
+	h.Operator(http.MethodGet, "/api/v1/incidents?only_active=false", nil).Expect(http.StatusOK).JSON(&all)
+	if all.Total != 1 || active.Total != 0 {
+		t.Fatalf("totals: all=%d active=%d", all.Total, active.Total)
+	}
+
+	h.Operator(http.MethodGet, "/api/v1/incidents/999", nil).Expect(http.StatusNotFound)
+	h.Operator(http.MethodPost, "/api/v1/incidents", map[string]any{"title": "No coordinates"}).Expect(http.StatusBadRequest)
+
+	var types []webhook.EventType
+	for _, e := range h.Webhooks.Events() {
+		types = append(types, e.Type)
+	}
+	want := []webhook.EventType{webhook.EventIncidentCreated, webhook.EventIncidentUpdated, webhook.EventIncidentDeactivated}
+	if fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+}
+
+func TestLocationCheckUsesCacheAndInvalidation(t *testing.T) {
+	h := apitest.New(t, apitest.Options{})
+	inc := createIncident(t, h, "Flood", 55.75, 37.61, 500)
+
+	check := func(lat, lon float64) checkResponse {
+		t.Helper()
+		var out checkResponse
+		h.Do(http.MethodPost, "/api/v1/location/check", map[string]any{
+			"user_id": "u1", "latitude": lat, "longitude": lon,
+		}).Expect(http.StatusOK).JSON(&out)
+		return out
+	}
+
+	if res := check(55.751, 37.611); !res.Dangerous || len(res.Incidents) != 1 || res.Incidents[0].ID != inc.ID {
+		t.Fatalf("inside the zone: %+v", res)
+	}
+	if res := check(55.80, 37.70); res.Dangerous {
+		t.Fatalf("outside the zone: %+v", res)
+	}
+	if got := len(h.Checks.Checks(tenant.WithID(t.Context(), tenant.DefaultID))); got != 2 {
+		t.Fatalf("checks recorded = %d", got)
+	}
+	const scope = "active_incidents:1"
+	v, _ := h.Versions.Current(t.Context(), scope)
+	if warm, _ := h.Cache.Exists(t.Context(), cache.VersionedKey(scope, v)); !warm {
+		t.Fatal("active incidents are not cached after a check")
+	}
+
+	h.Operator(http.MethodDelete, fmt.Sprintf("/api/v1/incidents/%d", inc.ID), nil).Expect(http.StatusOK)
+	if next, _ := h.Versions.Current(t.Context(), scope); next != v+1 {
+		t.Fatalf("cache version after deactivate = %d, want %d", next, v+1)
+	}
+	if stale, _ := h.Cache.Exists(t.Context(), cache.VersionedKey(scope, v)); stale {
+		t.Fatal("old cache version was not dropped")
+	}
+	if res := check(55.751, 37.611); res.Dangerous {
+		t.Fatalf("deactivated incident still matched: %+v", res)
+	}
+
+	dangerous := 0
+	for _, e := range h.Webhooks.Events() {
+		if e.Type == webhook.EventLocationDangerous {
+			dangerous++
+		}
+	}
+	if dangerous != 1 {
+		t.Fatalf("location.dangerous events = %d, want 1", dangerous)
+	}
+
+	h.Do(http.MethodPost, "/api/v1/location/check", map[string]any{
+		"user_id": "u1", "latitude": 95.0, "longitude": 37.6,
+	}).Expect(http.StatusBadRequest)
+}
+
+func TestLocationCheckDegradedMode(t *testing.T) {
+	h := apitest.New(t, apitest.Options{CacheTTL: -1, Tenants: map[string]int64{"acme": 2}})
+	inc := createIncident(t, h, "Gas leak", 55.75, 37.61, 500)
+	body := map[string]any{"user_id": "u1", "latitude": 55.751, "longitude": 37.611}
+
+	var fresh checkResponse
+	h.Do(http.MethodPost, "/api/v1/location/check", body).Expect(http.StatusOK).JSON(&fresh)
+	if fresh.Stale || fresh.SnapshotAge != nil {
+		t.Fatalf("healthy check marked stale: %+v", fresh)
+	}
+
+	down := common.NewError(common.CodeUnavailable, "connection refused")
+	h.Incident.Fail(down)
+	h.Checks.Fail(down)
+	var stale checkResponse
+	h.Do(http.MethodPost, "/api/v1/location/check", body).Expect(http.StatusOK).JSON(&stale)
+	if !stale.Stale || stale.SnapshotAge == nil || !stale.Dangerous || stale.Incidents[0].ID != inc.ID {
+		t.Fatalf("degraded check: %+v", stale)
+	}
+	if n, _ := h.Spool.Len(t.Context()); n != 1 {
+		t.Fatalf("spooled checks = %d, want 1", n)
+	}
+	h.Do(http.MethodPost, "/api/v1/location/check", body, middleware.TenantHeader, "acme").Expect(http.StatusServiceUnavailable)
+
+	h.Checks.Fail(nil)
+	if n := h.ReplaySpool(); n != 1 {
+		t.Fatalf("replayed = %d, want 1", n)
+	}
+	if got := len(h.Checks.Checks(tenant.WithID(t.Context(), tenant.DefaultID))); got != 2 {
+		t.Fatalf("saved checks = %d, want 2", got)
+	}
+
+	// A check that fails for another reason is dropped instead of blocking
+	// the spool.
+	h.Checks.Fail(down)
+	h.Do(http.MethodPost, "/api/v1/location/check", body).Expect(http.StatusOK)
+	h.Checks.Fail(common.NewError(common.CodeIternalErr, "violates check constraint"))
+	if n := h.ReplaySpool(); n != 0 {
+		t.Fatalf("replayed = %d, want 0", n)
+	}
+	if n, _ := h.Spool.Len(t.Context()); n != 0 {
+		t.Fatalf("spooled checks after drop = %d, want 0", n)
+	}
+}
+
+func TestStatsCountsUniqueUsers(t *testing.T) {
+	h := apitest.New(t, apitest.Options{})
+	for _, user := range []string{"a", "b", "a"} {
+		h.Do(http.MethodPost, "/api/v1/location/check", map[string]any{
+			"user_id": user, "latitude": 10.0, "longitude": 10.0,
+		}).Expect(http.StatusOK)
+	}
+
+	var stats struct {
+		UserCount int64 `json:"user_count"`
+	}
+	h.Operator(http.MethodGet, "/api/v1/incidents/stats", nil).Expect(http.StatusOK).JSON(&stats)
+	if stats.UserCount != 2 {
+		t.Fatalf("user_count = %d, want 2", stats.UserCount)
+	}
+}
+
+func TestOperatorAuth(t *testing.T) {
+	h := apitest.New(t, apitest.Options{
+		APIKeys: map[string]apikeys.Principal{
+			"viewer-key": {Name: "viewer", Roles: []string{apikeys.RoleViewer}, TenantID: 1},
+		},
+		Tenants: map[string]int64{"acme": 2},
+	})
+	body := map[string]any{"title": "Smoke", "latitude": 1.0, "longitude": 1.0}
+
+	h.Do(http.MethodGet, "/api/v1/incidents", nil).Expect(http.StatusUnauthorized)
+	h.Do(http.MethodGet, "/api/v1/incidents", nil, "x-api-key", "wrong").Expect(http.StatusUnauthorized)
+	h.Do(http.MethodGet, "/api/v1/incidents", nil, "x-api-key", "viewer-key").Expect(http.StatusOK)
+	h.Do(http.MethodPost, "/api/v1/incidents", body, "x-api-key", "viewer-key").Expect(http.StatusForbidden)
+	h.Do(http.MethodGet, "/api/v1/incidents", nil, "x-api-key", "viewer-key", middleware.TenantHeader, "acme").Expect(http.StatusForbidden)
+	h.Operator(http.MethodGet, "/api/v1/incidents", nil, middleware.TenantHeader, "unknown").Expect(http.StatusNotFound)
+}
+
+func TestTenantIsolation(t *testing.T) {
+	h := apitest.New(t, apitest.Options{Tenants: map[string]int64{"acme": 2}})
+	inc := createIncident(t, h, "Acme only", 20.0, 20.0, 1000, middleware.TenantHeader, "acme")
+
+	h.Operator(http.MethodGet, fmt.Sprintf("/api/v1/incidents/%d", inc.ID), nil).Expect(http.StatusNotFound)
+	h.Operator(http.MethodGet, fmt.Sprintf("/api/v1/incidents/%d", inc.ID), nil, middleware.TenantHeader, "acme").Expect(http.StatusOK)
+
+	var res checkResponse
+	h.Do(http.MethodPost, "/api/v1/location/check", map[string]any{
+		"user_id": "u1", "latitude": 20.0, "longitude": 20.0,
+	}).Expect(http.StatusOK).JSON(&res)
+	if res.Dangerous {
+		t.Fatal("default tenant sees another tenant's incident")
+	}
+	h.Do(http.MethodPost, "/api/v1/location/check", map[string]any{
+		"user_id": "u1", "latitude": 20.0, "longitude": 20.0,
+	}, middleware.TenantHeader, "acme").Expect(http.StatusOK).JSON(&res)
+	if !res.Dangerous {
+		t.Fatal("tenant does not see its own incident")
+	}
+}
+
+func TestHealthProbes(t *testing.T) {
+	h := apitest.New(t, apitest.Options{})
+	h.Do(http.MethodGet, "/livez", nil).Expect(http.StatusOK)
+	h.Do(http.MethodGet, "/readyz", nil).Expect(http.StatusOK)
+}
+
+func TestRouterRejectsInvalidTrustedProxies(t *testing.T) {
+	if _, err := routes.NewRouter(routes.RouterDeps{TrustedProxies: []string{"not-a-proxy"}}); err == nil {
+		t.Fatal("invalid trusted proxy accepted")
+	}
+}
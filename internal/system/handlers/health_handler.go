@@ -1,45 +1,33 @@
 package system
 
 import (
+	"RedColarTest/internal/system/services"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/redis/go-redis/v9"
 )
 
 type Handler struct {
-	db    *pgxpool.Pool
-	redis *redis.Client
+	health *services.HealthService
 }
 
-func NewHandler(db *pgxpool.Pool, redis *redis.Client) *Handler {
-	return &Handler{db: db, redis: redis}
+func NewHandler(health *services.HealthService) *Handler {
+	return &Handler{health: health}
 }
 
-func (h *Handler) Health(c *gin.Context) {
-	dbOK := true
-	if h.db != nil {
-		if err := h.db.Ping(c.Request.Context()); err != nil {
-			dbOK = false
-		}
-	}
-
-	redisOK := true
-	if h.redis != nil {
-		if err := h.redis.Ping(c.Request.Context()).Err(); err != nil {
-			redisOK = false
-		}
-	}
+// Livez only tells that the process serves HTTP; it never checks
+// dependencies, so an outage of Postgres or Redis does not cause restarts.
+func (h *Handler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": services.StatusOK})
+}
 
+// Readyz is 503 only when a critical check is down; failures of non-critical
+// checks give 200 with status "degraded".
+func (h *Handler) Readyz(c *gin.Context) {
+	report := h.health.Ready(c.Request.Context())
 	status := http.StatusOK
-	if !dbOK || !redisOK {
+	if report.Status == services.StatusDown {
 		status = http.StatusServiceUnavailable
 	}
-
-	c.JSON(status, gin.H{
-		"status":   "ok",
-		"db_ok":    dbOK,
-		"redis_ok": redisOK,
-	})
+	c.JSON(status, report)
 }
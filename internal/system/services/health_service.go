@@ -0,0 +1,207 @@
+package services
+
+import (
+	"RedColarTest/internal/webhook"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+
+	CheckUp   = "up"
+	CheckDown = "down"
+)
+
+// Check probes one dependency. Details are reported as-is; a non-nil error
+// marks the check down.
+type Check struct {
+	Name string
+	// Critical checks fail readiness; others only degrade it.
+	Critical bool
+	Timeout  time.Duration
+	Probe    func(ctx context.Context) (details map[string]any, err error)
+}
+
+type CheckResult struct {
+	Status    string         `json:"status"`
+	Critical  bool           `json:"critical"`
+	LatencyMs int64          `json:"latency_ms"`
+	Error     string         `json:"error,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type HealthService struct {
+	checks   []Check
+	timeout  time.Duration
+	stopping atomic.Bool
+}
+
+// NewHealthService runs checks with timeout unless a check sets its own.
+// Checks named in nonCritical are downgraded regardless of their default.
+func NewHealthService(timeout time.Duration, nonCritical []string, checks ...Check) *HealthService {
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	skip := make(map[string]bool, len(nonCritical))
+	for _, name := range nonCritical {
+		skip[name] = true
+	}
+	for i := range checks {
+		if skip[checks[i].Name] {
+			checks[i].Critical = false
+		}
+	}
+	return &HealthService{checks: checks, timeout: timeout}
+}
+
+// SetStopping makes readiness fail so load balancers stop routing here while
+// the server drains.
+func (s *HealthService) SetStopping() {
+	s.stopping.Store(true)
+}
+
+// Ready runs all checks in parallel.
+func (s *HealthService) Ready(ctx context.Context) Report {
+	results := make([]CheckResult, len(s.checks))
+	var wg sync.WaitGroup
+	for i, check := range s.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = s.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(results))}
+	for i, res := range results {
+		report.Checks[s.checks[i].Name] = res
+		if res.Status == CheckUp {
+			continue
+		}
+		if res.Critical {
+			report.Status = StatusDown
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+	if s.stopping.Load() {
+		report.Status = StatusDown
+		report.Checks["shutdown"] = CheckResult{Status: CheckDown, Critical: true, Error: "server is shutting down"}
+	}
+	return report
+}
+
+func (s *HealthService) run(ctx context.Context, check Check) CheckResult {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = s.timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := check.Probe(ctx)
+	res := CheckResult{
+		Status:    CheckUp,
+		Critical:  check.Critical,
+		LatencyMs: time.Since(start).Milliseconds(),
+		Details:   details,
+	}
+	if err != nil {
+		res.Status = CheckDown
+		res.Error = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			res.Error = fmt.Sprintf("timed out after %s", timeout)
+		}
+	}
+	return res
+}
+
+// PostgresCheck pings the pool and reports the applied migration version.
+// A dirty migration state counts as down.
+func PostgresCheck(db *pgxpool.Pool) Check {
+	return Check{Name: "postgres", Critical: true, Probe: func(ctx context.Context) (map[string]any, error) {
+		if err := db.Ping(ctx); err != nil {
+			return nil, err
+		}
+		var (
+			version int64
+			dirty   bool
+		)
+		err := db.QueryRow(ctx, `select version, dirty from schema_migrations limit 1`).Scan(&version, &dirty)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return map[string]any{"migration_version": nil}, errors.New("no migrations applied")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read migration version: %w", err)
+		}
+		details := map[string]any{"migration_version": version, "migration_dirty": dirty}
+		if dirty {
+			return details, fmt.Errorf("migration %d is dirty", version)
+		}
+		stat := db.Stat()
+		details["conns_total"] = stat.TotalConns()
+		details["conns_idle"] = stat.IdleConns()
+		return details, nil
+	}}
+}
+
+func RedisCheck(client *redis.Client) Check {
+	return Check{Name: "redis", Critical: true, Probe: func(ctx context.Context) (map[string]any, error) {
+		return nil, client.Ping(ctx).Err()
+	}}
+}
+
+type QueueStats interface {
+	Stats(ctx context.Context) (webhook.QueueStats, error)
+}
+
+// WebhookQueueCheck reports the backlog and fails when no worker has gone
+// round its loop within maxAge.
+func WebhookQueueCheck(q QueueStats, maxAge time.Duration) Check {
+	return Check{Name: "webhook_queue", Probe: func(ctx context.Context) (map[string]any, error) {
+		stats, err := q.Stats(ctx)
+		details := map[string]any{"ready": stats.Ready, "delayed": stats.Delayed}
+		if stats.LastHeartbeat.IsZero() {
+			details["last_heartbeat"] = nil
+			return details, errors.Join(err, errors.New("workers have not started"))
+		}
+		age := time.Since(stats.LastHeartbeat)
+		details["last_heartbeat"] = stats.LastHeartbeat.UTC()
+		details["heartbeat_age_ms"] = age.Milliseconds()
+		if maxAge > 0 && age > maxAge {
+			err = errors.Join(err, fmt.Errorf("no worker heartbeat for %s", age.Round(time.Second)))
+		}
+		return details, err
+	}}
+}
+
+type CacheWarmth interface {
+	CacheWarm(ctx context.Context) (warm, enabled bool, err error)
+}
+
+// CacheCheck reports whether the active incidents cache is filled. A cold
+// cache is not a failure, it only means the next check goes to Postgres.
+func CacheCheck(c CacheWarmth) Check {
+	return Check{Name: "cache", Probe: func(ctx context.Context) (map[string]any, error) {
+		warm, enabled, err := c.CacheWarm(ctx)
+		return map[string]any{"enabled": enabled, "warm": warm}, err
+	}}
+}
@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"RedColarTest/internal/common"
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultID is the tenant that owns data created before multi-tenancy and
+// serves requests that do not name a tenant.
+const DefaultID int64 = 1
+
+type Tenant struct {
+	ID        int64     `json:"id"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ctxKey struct{}
+
+func WithID(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// ID returns the tenant the request is scoped to. Every HTTP request gets one
+// from the tenant middleware; background work sets it explicitly.
+func ID(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(ctxKey{}).(int64)
+	return id, ok && id > 0
+}
+
+// Require is ID for tenant-scoped reads and writes: a context without a
+// tenant is a bug in the caller and fails the operation instead of touching
+// another tenant's rows.
+func Require(ctx context.Context) (int64, *common.Error) {
+	id, ok := ID(ctx)
+	if !ok {
+		return 0, common.NewError(common.CodeIternalErr, "tenant: context has no tenant")
+	}
+	return id, nil
+}
+
+// ScopedKey suffixes a shared Redis key with the context tenant.
+func ScopedKey(ctx context.Context, base string) (string, *common.Error) {
+	id, err := Require(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%d", base, id), nil
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"RedColarTest/internal/common"
+	"RedColarTest/internal/tenants/domain"
+	"RedColarTest/internal/tenants/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TenantHandler struct {
+	svc *services.TenantService
+}
+
+func NewTenantHandler(svc *services.TenantService) *TenantHandler {
+	return &TenantHandler{svc: svc}
+}
+
+type createTenantRequest struct {
+	Slug string `json:"slug" binding:"required"`
+	Name string `json:"name" binding:"required"`
+}
+
+type updateTenantRequest struct {
+	Name     string `json:"name" binding:"required"`
+	IsActive *bool  `json:"is_active" binding:"required"`
+}
+
+func (h *TenantHandler) Create(c *gin.Context) {
+	var req createTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	out, err := h.svc.Create(c.Request.Context(), domain.Tenant{Slug: req.Slug, Name: req.Name, IsActive: true})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, out)
+}
+
+func (h *TenantHandler) List(c *gin.Context) {
+	items, err := h.svc.List(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func (h *TenantHandler) Update(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req updateTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	out, errorDto := h.svc.Update(c.Request.Context(), id, domain.Tenant{Name: req.Name, IsActive: *req.IsActive})
+	if errorDto != nil {
+		writeError(c, errorDto)
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func writeError(c *gin.Context, err *common.Error) {
+	switch err.Code {
+	case common.CodeNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	case common.CodeNotValid:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case common.CodeUnavailable:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
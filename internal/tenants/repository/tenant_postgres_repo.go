@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"RedColarTest/internal/common"
+	"RedColarTest/internal/logging"
+	"RedColarTest/internal/tenants/domain"
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const tenantColumns = `id, slug, name, is_active, created_at, updated_at`
+
+type TenantRepo struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewTenantRepo(db *pgxpool.Pool, logger *slog.Logger) *TenantRepo {
+	return &TenantRepo{db: db, log: logging.OrDiscard(logger)}
+}
+
+func (r *TenantRepo) Create(ctx context.Context, in domain.Tenant) (domain.Tenant, *common.Error) {
+	const q = `
+insert into tenants (slug, name, is_active)
+values ($1, $2, $3)
+returning ` + tenantColumns + `;
+`
+	out, err := scanTenant(r.db.QueryRow(ctx, q, in.Slug, in.Name, in.IsActive))
+	if err != nil {
+		return domain.Tenant{}, common.Internal(ctx, r.log, "TenantRepo.Create", err)
+	}
+	return out, nil
+}
+
+func (r *TenantRepo) GetByID(ctx context.Context, id int64) (domain.Tenant, *common.Error) {
+	const q = `select ` + tenantColumns + ` from tenants where id = $1;`
+	return r.get(ctx, q, id)
+}
+
+func (r *TenantRepo) GetBySlug(ctx context.Context, slug string) (domain.Tenant, *common.Error) {
+	const q = `select ` + tenantColumns + ` from tenants where slug = $1;`
+	return r.get(ctx, q, slug)
+}
+
+func (r *TenantRepo) List(ctx context.Context) ([]domain.Tenant, *common.Error) {
+	const q = `select ` + tenantColumns + ` from tenants order by id;`
+	rows, err := r.db.Query(ctx, q)
+	if err != nil {
+		return nil, common.Internal(ctx, r.log, "TenantRepo.List", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.Tenant, 0)
+	for rows.Next() {
+		it, err := scanTenant(rows)
+		if err != nil {
+			return nil, common.Internal(ctx, r.log, "TenantRepo.List", err)
+		}
+		items = append(items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, common.Internal(ctx, r.log, "TenantRepo.List", err)
+	}
+	return items, nil
+}
+
+func (r *TenantRepo) Update(ctx context.Context, id int64, in domain.Tenant) (domain.Tenant, *common.Error) {
+	const q = `
+update tenants
+set name = $2,
+    is_active = $3,
+    updated_at = now()
+where id = $1
+returning ` + tenantColumns + `;
+`
+	out, err := scanTenant(r.db.QueryRow(ctx, q, id, in.Name, in.IsActive))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Tenant{}, common.NewError(common.CodeNotFound, err.Error())
+	}
+	if err != nil {
+		return domain.Tenant{}, common.Internal(ctx, r.log, "TenantRepo.Update", err)
+	}
+	return out, nil
+}
+
+func (r *TenantRepo) get(ctx context.Context, q string, arg any) (domain.Tenant, *common.Error) {
+	out, err := scanTenant(r.db.QueryRow(ctx, q, arg))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Tenant{}, common.NewError(common.CodeNotFound, err.Error())
+	}
+	if err != nil {
+		return domain.Tenant{}, common.Internal(ctx, r.log, "TenantRepo.get", err)
+	}
+	return out, nil
+}
+
+func scanTenant(row pgx.Row) (domain.Tenant, error) {
+	var out domain.Tenant
+	err := row.Scan(
+		&out.ID,
+		&out.Slug,
+		&out.Name,
+		&out.IsActive,
+		&out.CreatedAt,
+		&out.UpdatedAt,
+	)
+	return out, err
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"RedColarTest/internal/cache"
+	"RedColarTest/internal/common"
+	"RedColarTest/internal/tenants/domain"
+	"RedColarTest/internal/tenants/repository"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	resolveCacheTTL = time.Minute
+	// tenantsScope is bumped on the cache bus when a tenant changes, so every
+	// replica drops its resolved tenants at once rather than after the TTL.
+	tenantsScope = "tenants"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,63}$`)
+
+type cachedTenant struct {
+	tenant    domain.Tenant
+	version   int64
+	expiresAt time.Time
+}
+
+type TenantService struct {
+	repo     *repository.TenantRepo
+	versions cache.Versions
+
+	mu    sync.Mutex
+	cache map[string]cachedTenant
+}
+
+// NewTenantService caches resolved tenants for resolveCacheTTL. With versions
+// shared between replicas (the cache bus) a tenant change reaches all of them
+// right away; with nil or per-process versions other replicas see it once
+// their entries expire.
+func NewTenantService(repo *repository.TenantRepo, versions cache.Versions) *TenantService {
+	return &TenantService{repo: repo, versions: versions, cache: make(map[string]cachedTenant)}
+}
+
+func (s *TenantService) Create(ctx context.Context, in domain.Tenant) (domain.Tenant, *common.Error) {
+	if err := validateTenant(in); err != nil {
+		return domain.Tenant{}, common.NewError(common.CodeNotValid, err.Error())
+	}
+	if !slugPattern.MatchString(in.Slug) {
+		return domain.Tenant{}, common.NewError(common.CodeNotValid, "slug must match "+slugPattern.String())
+	}
+	return s.repo.Create(ctx, in)
+}
+
+func (s *TenantService) List(ctx context.Context) ([]domain.Tenant, *common.Error) {
+	return s.repo.List(ctx)
+}
+
+func (s *TenantService) Update(ctx context.Context, id int64, in domain.Tenant) (domain.Tenant, *common.Error) {
+	if id <= 0 {
+		return domain.Tenant{}, common.NewError(common.CodeNotValid, fmt.Sprintf("Tenant with id %d not found", id))
+	}
+	if err := validateTenant(in); err != nil {
+		return domain.Tenant{}, common.NewError(common.CodeNotValid, err.Error())
+	}
+	if id == domain.DefaultID && !in.IsActive {
+		return domain.Tenant{}, common.NewError(common.CodeNotValid, "default tenant cannot be deactivated")
+	}
+	out, err := s.repo.Update(ctx, id, in)
+	if err == nil {
+		s.forget()
+		if s.versions != nil {
+			_, _ = s.versions.Bump(ctx, tenantsScope)
+		}
+	}
+	return out, err
+}
+
+// ResolveTenant looks up an active tenant by slug or numeric id. Known tenants
+// are cached for a minute since it runs on every request; unknown refs are not
+// cached so the cache stays bounded by the number of tenants.
+func (s *TenantService) ResolveTenant(ctx context.Context, ref string) (int64, bool) {
+	id, parseErr := strconv.ParseInt(ref, 10, 64)
+	switch {
+	case parseErr == nil && id <= 0:
+		return 0, false
+	case parseErr == nil:
+		ref = strconv.FormatInt(id, 10)
+	case !slugPattern.MatchString(ref):
+		return 0, false
+	}
+
+	now := time.Now()
+	version := s.version(ctx)
+	s.mu.Lock()
+	cached, ok := s.cache[ref]
+	s.mu.Unlock()
+	if ok && cached.version == version && now.Before(cached.expiresAt) {
+		return cached.tenant.ID, cached.tenant.IsActive
+	}
+
+	var (
+		tenant domain.Tenant
+		err    *common.Error
+	)
+	if parseErr == nil {
+		tenant, err = s.repo.GetByID(ctx, id)
+	} else {
+		tenant, err = s.repo.GetBySlug(ctx, ref)
+	}
+	if err != nil {
+		return 0, false
+	}
+	s.remember(ref, tenant, version, now)
+	return tenant.ID, tenant.IsActive
+}
+
+// version is the current tenants version; on errors it is -1, which matches
+// no cached entry, so tenants are read from Postgres until the bus recovers.
+func (s *TenantService) version(ctx context.Context) int64 {
+	if s.versions == nil {
+		return 0
+	}
+	v, err := s.versions.Current(ctx, tenantsScope)
+	if err != nil {
+		return -1
+	}
+	return v
+}
+
+func (s *TenantService) remember(ref string, tenant domain.Tenant, version int64, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache[ref] = cachedTenant{tenant: tenant, version: version, expiresAt: now.Add(resolveCacheTTL)}
+}
+
+func (s *TenantService) forget() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.cache)
+}
+
+func validateTenant(in domain.Tenant) error {
+	if in.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultBatchWindow  = 5 * time.Second
+	defaultBatchMaxSize = 100
+)
+
+// flushBatchLua defines flush, which moves up to size members of a batch list
+// into a single batch job on the queue and reschedules the list in the due
+// set at nextDue if members remain, or removes it.
+const flushBatchLua = `
+local function flush(list, dueSet, queue, tenantID, destID, size, nextDue)
+  local items = redis.call('LRANGE', list, 0, size - 1)
+  if #items == 0 then
+    redis.call('ZREM', dueSet, list)
+    return 0
+  end
+  redis.call('LTRIM', list, #items, -1)
+  if redis.call('LLEN', list) == 0 then
+    redis.call('ZREM', dueSet, list)
+  else
+    redis.call('ZADD', dueSet, nextDue, list)
+  end
+  redis.call('LPUSH', queue, '{"tenant_id":' .. tenantID .. ',"subscription_id":' .. destID ..
+    ',"batch":[' .. table.concat(items, ',') .. ']}')
+  return #items
+end
+`
+
+// addBatchMember appends ARGV[1] to the batch list KEYS[1]. The first member
+// schedules the batch in KEYS[2] at ARGV[2]; reaching the size limit ARGV[3]
+// flushes it to the queue KEYS[3] straight away.
+var addBatchMember = redis.NewScript(flushBatchLua + `
+local n = redis.call('RPUSH', KEYS[1], ARGV[1])
+if n == 1 then
+  redis.call('ZADD', KEYS[2], 'NX', ARGV[2], KEYS[1])
+end
+local size = tonumber(ARGV[3])
+if n >= size then
+  return flush(KEYS[1], KEYS[2], KEYS[3], ARGV[4], ARGV[5], size, ARGV[2])
+end
+return 0
+`)
+
+// flushDueBatch flushes the batch list KEYS[1] to the queue KEYS[3] if it is
+// still due in KEYS[2] at ARGV[1]; another replica may have flushed it since
+// it was listed.
+var flushDueBatch = redis.NewScript(flushBatchLua + `
+local due = redis.call('ZSCORE', KEYS[2], KEYS[1])
+if not due or tonumber(due) > tonumber(ARGV[1]) then
+  return 0
+end
+return flush(KEYS[1], KEYS[2], KEYS[3], ARGV[2], ARGV[3], redis.call('LLEN', KEYS[1]), ARGV[1])
+`)
+
+// addToBatch buffers a job for a batching destination in Redis, so pending
+// batch members survive a crash. The batch is pushed back to the queue as a
+// single job once it reaches the size limit or its window elapses, so delivery
+// still goes through the concurrency limit, breaker and retries.
+func (q *Queue) addToBatch(ctx context.Context, dest destination, j job) error {
+	member, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	due := time.Now().Add(dest.batchWindow).UnixMilli()
+	return addBatchMember.Run(ctx, q.redis,
+		[]string{q.batchKey(j.TenantID, dest.id), q.batchesKey, q.queueKey},
+		member, due, dest.batchSize, j.TenantID, dest.id).Err()
+}
+
+func (q *Queue) batchKey(tenantID, destID int64) string {
+	return fmt.Sprintf("%s:batch:%d:%d", q.queueKey, tenantID, destID)
+}
+
+// flushDue pushes batches whose window has elapsed to the queue. Due lists
+// are read first and flushed one script call each, so every key a script
+// touches is passed in KEYS.
+func (q *Queue) flushDue(ctx context.Context) error {
+	prefix := q.queueKey + ":batch:"
+	for {
+		now := time.Now().UnixMilli()
+		due, err := q.redis.ZRangeByScore(ctx, q.batchesKey, &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   strconv.FormatInt(now, 10),
+			Count: promoteBatch,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		for _, list := range due {
+			var tenantID, destID int64
+			if _, err := fmt.Sscanf(strings.TrimPrefix(list, prefix), "%d:%d", &tenantID, &destID); err != nil {
+				q.redis.ZRem(ctx, q.batchesKey, list)
+				continue
+			}
+			err := flushDueBatch.Run(ctx, q.redis, []string{list, q.batchesKey, q.queueKey},
+				now, tenantID, destID).Err()
+			if err != nil {
+				return err
+			}
+		}
+		if len(due) < promoteBatch {
+			return nil
+		}
+	}
+}
+
+// unbatch turns a flushed batch job into one carrying the batched events.
+// The request id and trace of the first member stand for the whole batch.
+func unbatch(j job) job {
+	if len(j.Batch) == 0 {
+		return j
+	}
+	first := j.Batch[0]
+	out := job{
+		TenantID:       j.TenantID,
+		RequestID:      first.RequestID,
+		Trace:          first.Trace,
+		SubscriptionID: j.SubscriptionID,
+		Attempt:        j.Attempt,
+	}
+	for _, m := range j.Batch {
+		if m.Event != nil {
+			out.Events = append(out.Events, *m.Event)
+		}
+	}
+	return out
+}
@@ -0,0 +1,29 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUnbatchFlushedBatch(t *testing.T) {
+	first, second := Event{ID: "e1", Type: EventIncidentCreated}, Event{ID: "e2", Type: EventIncidentUpdated}
+	m1, _ := json.Marshal(job{TenantID: 3, RequestID: "req-1", SubscriptionID: 9, Event: &first})
+	m2, _ := json.Marshal(job{TenantID: 3, RequestID: "req-2", SubscriptionID: 9, Event: &second})
+	// The shape flushBatchLua pushes to the queue.
+	raw := `{"tenant_id":3,"subscription_id":9,"batch":[` + string(m1) + `,` + string(m2) + `]}`
+
+	var j job
+	if err := json.Unmarshal([]byte(raw), &j); err != nil {
+		t.Fatal(err)
+	}
+	got := unbatch(j)
+	if got.TenantID != 3 || got.SubscriptionID != 9 || got.RequestID != "req-1" || got.Event != nil {
+		t.Fatalf("unbatched job = %+v", got)
+	}
+	if len(got.Events) != 2 || got.Events[0].ID != "e1" || got.Events[1].ID != "e2" {
+		t.Fatalf("events = %+v", got.Events)
+	}
+	if d := describeJob("ready", raw, nil); d.Kind != "batch" || len(d.EventIDs) != 2 {
+		t.Fatalf("describeJob = %+v", d)
+	}
+}
@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	b := newBreaker(3, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		b.failure(now)
+	}
+	if ok, _ := b.allow(now); !ok {
+		t.Fatal("breaker opened before the threshold")
+	}
+	b.success()
+	for i := 0; i < 2; i++ {
+		b.failure(now)
+	}
+	if ok, _ := b.allow(now); !ok {
+		t.Fatal("success did not reset the failure count")
+	}
+
+	b.failure(now)
+	if ok, wait := b.allow(now.Add(20 * time.Second)); ok || wait != 40*time.Second {
+		t.Fatalf("open breaker: allow = %v, wait = %s", ok, wait)
+	}
+
+	probeAt := now.Add(time.Minute)
+	if ok, _ := b.allow(probeAt); !ok {
+		t.Fatal("no probe after the cooldown")
+	}
+	if ok, wait := b.allow(probeAt); ok || wait != time.Minute {
+		t.Fatalf("second half-open delivery: allow = %v, wait = %s", ok, wait)
+	}
+
+	b.failure(probeAt)
+	if ok, _ := b.allow(probeAt.Add(time.Second)); ok {
+		t.Fatal("failed probe did not re-open the breaker")
+	}
+	if ok, _ := b.allow(probeAt.Add(time.Minute)); !ok {
+		t.Fatal("no probe after the second cooldown")
+	}
+	b.success()
+	for i := 0; i < 3; i++ {
+		if ok, _ := b.allow(probeAt.Add(time.Minute)); !ok {
+			t.Fatal("successful probe did not close the breaker")
+		}
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	b := newBreaker(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		b.failure(now)
+	}
+	if ok, _ := b.allow(now); !ok {
+		t.Fatal("breaker with threshold 0 rejected a delivery")
+	}
+}
@@ -0,0 +1,133 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	cloudEventsSource      = "/redcolar/incidents-api"
+	cloudEventsTypePrefix  = "ru.redcolar."
+	cloudEventsSchemaURN   = "urn:redcolar:schema:"
+)
+
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	DataSchema      string          `json:"dataschema"`
+	Data            json.RawMessage `json:"data"`
+}
+
+func cloudEventType(t EventType) string {
+	return cloudEventsTypePrefix + string(t)
+}
+
+func cloudEventSchema(t EventType) string {
+	return cloudEventsSchemaURN + string(t) + ":v1"
+}
+
+func newCloudEvent(event Event) cloudEvent {
+	return cloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		ID:              event.ID,
+		Source:          cloudEventsSource,
+		Type:            cloudEventType(event.Type),
+		Time:            event.OccurredAt,
+		DataContentType: "application/json",
+		DataSchema:      cloudEventSchema(event.Type),
+		Data:            event.Data,
+	}
+}
+
+// legacyBody keeps the original bare payload for check alerts and wraps
+// everything else in the event envelope.
+func legacyBody(event Event) ([]byte, error) {
+	if event.Type == EventLocationDangerous {
+		return event.Data, nil
+	}
+	return json.Marshal(event)
+}
+
+func newDeliveryRequest(ctx context.Context, dest destination, event Event) (*http.Request, error) {
+	var (
+		body        []byte
+		contentType = "application/json"
+		err         error
+	)
+	switch dest.format {
+	case FormatCloudEventsStructured:
+		contentType = "application/cloudevents+json"
+		body, err = json.Marshal(newCloudEvent(event))
+	case FormatCloudEventsBinary:
+		body = event.Data
+	default:
+		body, err = legacyBody(event)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dest.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Webhook-Event", string(event.Type))
+	req.Header.Set("X-Webhook-Event-Id", event.ID)
+	signRequest(req, body, dest.secret, time.Now())
+	if dest.format == FormatCloudEventsBinary {
+		req.Header.Set("ce-specversion", cloudEventsSpecVersion)
+		req.Header.Set("ce-id", event.ID)
+		req.Header.Set("ce-source", cloudEventsSource)
+		req.Header.Set("ce-type", cloudEventType(event.Type))
+		req.Header.Set("ce-time", event.OccurredAt.Format(time.RFC3339Nano))
+		req.Header.Set("ce-dataschema", cloudEventSchema(event.Type))
+	}
+	return req, nil
+}
+
+// newBatchRequest posts several events as a single JSON array. Binary
+// CloudEvents have no batch representation, so subscriptions in that format
+// cannot enable batching.
+func newBatchRequest(ctx context.Context, dest destination, events []Event) (*http.Request, error) {
+	contentType := "application/json"
+	items := make([]json.RawMessage, 0, len(events))
+	for _, event := range events {
+		var (
+			item []byte
+			err  error
+		)
+		if dest.format == FormatCloudEventsStructured {
+			contentType = "application/cloudevents-batch+json"
+			item, err = json.Marshal(newCloudEvent(event))
+		} else {
+			item, err = legacyBody(event)
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	body, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dest.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Webhook-Batch-Size", strconv.Itoa(len(events)))
+	signRequest(req, body, dest.secret, time.Now())
+	return req, nil
+}
@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// digestIncidents claims the digest key of each incident for the cooldown
+// ARGV[1]; KEYS holds the n digest keys followed by their n suppressed
+// counters. It returns -1 for incidents already reported, whose suppressed
+// counter is bumped, and otherwise the number of alerts suppressed since the
+// last report, in one round trip per destination.
+var digestIncidents = redis.NewScript(`
+local out = {}
+local n = #KEYS / 2
+for i = 1, n do
+  local key, suppressed = KEYS[i], KEYS[n + i]
+  if redis.call('SET', key, 1, 'NX', 'PX', ARGV[1]) then
+    out[i] = tonumber(redis.call('GET', suppressed)) or 0
+    redis.call('DEL', suppressed)
+  else
+    redis.call('INCR', suppressed)
+    redis.call('PEXPIRE', suppressed, ARGV[2])
+    out[i] = -1
+  end
+end
+return out
+`)
+
+// digest drops incidents from a check alert that were already reported to the
+// destination for the same user within the cooldown. Suppressed repeats are
+// counted and reported on the first alert after the cooldown expires. It
+// reports false when nothing is left to deliver.
+func (q *Queue) digest(ctx context.Context, dest destination, event Event) (Event, bool) {
+	if dest.digestCooldown <= 0 || event.Type != EventLocationDangerous {
+		return event, true
+	}
+	var payload Payload
+	if err := json.Unmarshal(event.Data, &payload); err != nil {
+		return event, true
+	}
+
+	n := len(payload.Incidents)
+	keys := make([]string, 2*n)
+	for i, inc := range payload.Incidents {
+		keys[i] = fmt.Sprintf("webhook:digest:%d:%s:%d", dest.id, payload.UserID, inc.ID)
+		keys[n+i] = keys[i] + ":suppressed"
+	}
+	res, err := digestIncidents.Run(ctx, q.redis, keys,
+		dest.digestCooldown.Milliseconds(), (dest.digestCooldown + time.Hour).Milliseconds()).Int64Slice()
+	if err != nil || len(res) != n {
+		return event, true
+	}
+
+	kept := make([]PayloadIncident, 0, len(payload.Incidents))
+	for i, inc := range payload.Incidents {
+		if res[i] < 0 {
+			continue
+		}
+		inc.SuppressedAlerts = int(res[i])
+		kept = append(kept, inc)
+	}
+	if len(kept) == 0 {
+		return event, false
+	}
+	if len(kept) == len(payload.Incidents) && !hasSuppressed(kept) {
+		return event, true
+	}
+
+	payload.Incidents = kept
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return event, true
+	}
+	event.Data = data
+	return event, true
+}
+
+func hasSuppressed(incidents []PayloadIncident) bool {
+	for _, inc := range incidents {
+		if inc.SuppressedAlerts > 0 {
+			return true
+		}
+	}
+	return false
+}
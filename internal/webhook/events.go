@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventIncidentCreated     EventType = "incident.created"
+	EventIncidentUpdated     EventType = "incident.updated"
+	EventIncidentDeactivated EventType = "incident.deactivated"
+	EventIncidentExpired     EventType = "incident.expired"
+	EventLocationDangerous   EventType = "location.dangerous"
+	EventPing                EventType = "ping"
+)
+
+type Event struct {
+	ID         string          `json:"id"`
+	Type       EventType       `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+type IncidentSnapshot struct {
+	ID            int64      `json:"id"`
+	Title         string     `json:"title"`
+	Description   *string    `json:"description,omitempty"`
+	Latitude      float64    `json:"latitude"`
+	Longitude     float64    `json:"longitude"`
+	DangerRadiusM int        `json:"danger_radius_m"`
+	IsActive      bool       `json:"is_active"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type IncidentEventData struct {
+	Before *IncidentSnapshot `json:"before,omitempty"`
+	After  IncidentSnapshot  `json:"after"`
+}
+
+func NewEvent(eventType EventType, data any) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		ID:         newEventID(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Data:       raw,
+	}, nil
+}
+
+func newEventID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
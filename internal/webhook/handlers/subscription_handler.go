@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"RedColarTest/internal/common"
+	"RedColarTest/internal/webhook"
+	"RedColarTest/internal/webhook/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SubscriptionHandler struct {
+	svc *services.SubscriptionService
+}
+
+func NewSubscriptionHandler(svc *services.SubscriptionService) *SubscriptionHandler {
+	return &SubscriptionHandler{svc: svc}
+}
+
+type subscriptionRequest struct {
+	URL            string         `json:"url" binding:"required"`
+	Format         webhook.Format `json:"format"`
+	Secret         string         `json:"secret"`
+	MaxConcurrency *int           `json:"max_concurrency"`
+	BatchWindowMs  *int           `json:"batch_window_ms"`
+	BatchMaxSize   *int           `json:"batch_max_size"`
+	DigestCooldown *int           `json:"digest_cooldown_seconds"`
+	IsActive       *bool          `json:"is_active"`
+}
+
+func (r subscriptionRequest) toSubscription() webhook.Subscription {
+	isActive := true
+	if r.IsActive != nil {
+		isActive = *r.IsActive
+	}
+	return webhook.Subscription{
+		URL:            r.URL,
+		Format:         r.Format,
+		Secret:         r.Secret,
+		MaxConcurrency: r.MaxConcurrency,
+		BatchWindowMs:  r.BatchWindowMs,
+		BatchMaxSize:   r.BatchMaxSize,
+		DigestCooldown: r.DigestCooldown,
+		IsActive:       isActive,
+	}
+}
+
+func (h *SubscriptionHandler) Create(c *gin.Context) {
+	var req subscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	out, err := h.svc.Create(c.Request.Context(), req.toSubscription())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, out)
+}
+
+func (h *SubscriptionHandler) List(c *gin.Context) {
+	items, err := h.svc.List(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func (h *SubscriptionHandler) GetByID(c *gin.Context) {
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	out, errorDto := h.svc.GetByID(c.Request.Context(), id)
+	if errorDto != nil {
+		writeError(c, errorDto)
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *SubscriptionHandler) Update(c *gin.Context) {
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req subscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	out, errorDto := h.svc.Update(c.Request.Context(), id, req.toSubscription())
+	if errorDto != nil {
+		writeError(c, errorDto)
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *SubscriptionHandler) Delete(c *gin.Context) {
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if errorDto := h.svc.Delete(c.Request.Context(), id); errorDto != nil {
+		writeError(c, errorDto)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *SubscriptionHandler) Ping(c *gin.Context) {
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	res, errorDto := h.svc.Ping(c.Request.Context(), id)
+	if errorDto != nil {
+		if errorDto.Code == common.CodeUnavailable && res.Latency > 0 {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error":      errorDto.Error(),
+				"latency_ms": res.Latency.Milliseconds(),
+			})
+			return
+		}
+		writeError(c, errorDto)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status_code": res.StatusCode,
+		"latency_ms":  res.Latency.Milliseconds(),
+		"body":        res.Body,
+	})
+}
+
+func writeError(c *gin.Context, err *common.Error) {
+	switch err.Code {
+	case common.CodeNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	case common.CodeNotValid:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case common.CodeUnavailable:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func parseID(s string) (int64, *common.Error) {
+	if s == "" {
+		return 0, common.NewError(common.CodeNotValid, "id is empty string")
+	}
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, common.NewError(common.CodeNotValid, "cannot parse id")
+	}
+	return id, nil
+}
@@ -1,13 +1,29 @@
 package webhook
 
 import (
-	"bytes"
+	"RedColarTest/internal/logging"
+	"RedColarTest/internal/metrics"
+	tenant "RedColarTest/internal/tenants/domain"
+	"RedColarTest/internal/tracing"
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
 	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type PayloadIncident struct {
@@ -18,6 +34,8 @@ type PayloadIncident struct {
 	Longitude     float64 `json:"longitude"`
 	DangerRadiusM int     `json:"danger_radius_m"`
 	DistanceM     float64 `json:"distance_m"`
+
+	SuppressedAlerts int `json:"suppressed_alerts,omitempty"`
 }
 
 type Payload struct {
@@ -29,54 +47,316 @@ type Payload struct {
 	CreatedAt time.Time         `json:"created_at"`
 }
 
+// job is a single delivery to one destination: either one Event or, for
+// batching destinations, a flushed batch in Events or Batch. SubscriptionID 0 stands for
+// the legacy WEBHOOK_URL receiver. Payload is only set on jobs enqueued before
+// events were introduced; TenantID is unset on jobs from before tenants and
+// then means the default tenant. RequestID ties the delivery to the request
+// that caused it and is sent as X-Request-ID; Trace carries its W3C trace
+// context so the delivery span joins the same trace.
 type job struct {
-	Payload Payload `json:"payload"`
-	Attempt int     `json:"attempt"`
+	TenantID       int64             `json:"tenant_id,omitempty"`
+	RequestID      string            `json:"request_id,omitempty"`
+	Trace          map[string]string `json:"trace,omitempty"`
+	SubscriptionID int64             `json:"subscription_id,omitempty"`
+	Event          *Event            `json:"event,omitempty"`
+	Events         []Event           `json:"events,omitempty"`
+	// Batch holds the member jobs of a batch flushed by a Redis script; the
+	// worker turns it into Events.
+	Batch   []job    `json:"batch,omitempty"`
+	Payload *Payload `json:"payload,omitempty"`
+	Attempt int      `json:"attempt"`
+}
+
+type destination struct {
+	id             int64
+	url            string
+	format         Format
+	secret         string
+	concurrency    int
+	batchWindow    time.Duration
+	batchSize      int
+	digestCooldown time.Duration
+}
+
+func (d destination) batching() bool {
+	return d.batchSize > 0
+}
+
+// label names the destination in metrics.
+func (d destination) label() string {
+	if d.id == 0 {
+		return "legacy"
+	}
+	return strconv.FormatInt(d.id, 10)
+}
+
+type QueueOptions struct {
+	Workers                int
+	DestinationConcurrency int
+	MaxRetries             int
+	RetryBase              time.Duration
+	RetryMax               time.Duration
+	RequestTimeout         time.Duration
+	BreakerThreshold       int
+	BreakerCooldown        time.Duration
+
+	// Signing, batching and digest settings for the WEBHOOK_URL receiver;
+	// subscriptions carry their own.
+	Secret         string
+	BatchWindow    time.Duration
+	BatchMaxSize   int
+	DigestCooldown time.Duration
+
+	Logger  *slog.Logger
+	Metrics *metrics.Metrics
 }
 
 type Queue struct {
 	redis      *redis.Client
+	subs       SubscriptionStore
 	webhookURL string
 	queueKey   string
-	maxRetries int
-	retryBase  time.Duration
+	delayedKey string
+	batchesKey string
+	opts       QueueOptions
 	client     *http.Client
+	log        *slog.Logger
+
+	mu    sync.Mutex
+	dests map[int64]*destState
+
+	// heartbeat is the unix nano time a worker last went round its loop.
+	heartbeat atomic.Int64
+}
+
+type destState struct {
+	sem     chan struct{}
+	breaker *breaker
 }
 
-func NewQueue(redisClient *redis.Client, webhookURL string, maxRetries int, retryBase time.Duration) *Queue {
+func NewQueue(redisClient *redis.Client, subs SubscriptionStore, webhookURL string, opts QueueOptions) *Queue {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.DestinationConcurrency <= 0 {
+		opts.DestinationConcurrency = 1
+	}
+	if opts.RequestTimeout <= 0 {
+		opts.RequestTimeout = 5 * time.Second
+	}
 	return &Queue{
 		redis:      redisClient,
+		subs:       subs,
 		webhookURL: webhookURL,
 		queueKey:   "queue:webhook",
-		maxRetries: maxRetries,
-		retryBase:  retryBase,
+		delayedKey: "queue:webhook:delayed",
+		batchesKey: "queue:webhook:batches",
+		opts:       opts,
 		client: &http.Client{
-			Timeout: 5 * time.Second,
+			Timeout: opts.RequestTimeout,
 		},
+		log:   logging.OrDiscard(opts.Logger),
+		dests: make(map[int64]*destState),
 	}
 }
 
 func (q *Queue) Enqueue(ctx context.Context, payload Payload) error {
-	if q == nil || q.redis == nil || q.webhookURL == "" {
-		return nil
-	}
-	b, err := json.Marshal(job{Payload: payload, Attempt: 0})
+	event, err := payloadEvent(payload)
 	if err != nil {
 		return err
 	}
-	return q.redis.LPush(ctx, q.queueKey, b).Err()
+	return q.Publish(ctx, event)
+}
+
+func (q *Queue) Publish(ctx context.Context, event Event) (err error) {
+	if q == nil || q.redis == nil {
+		return nil
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "webhook publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("webhook.event_type", string(event.Type))))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return cerr
+	}
+	dests := make([]destination, 0, 1)
+	if q.legacyEnabled(tenantID) {
+		dests = append(dests, q.legacyDestination())
+	}
+	if q.subs != nil {
+		subs, subsErr := q.subs.ListActive(ctx)
+		if subsErr != nil {
+			return subsErr
+		}
+		for _, sub := range subs {
+			dests = append(dests, q.subscriptionDestination(sub))
+		}
+	}
+
+	jobs := make([]any, 0, len(dests))
+	for _, dest := range dests {
+		out, ok := q.digest(ctx, dest, event)
+		if !ok {
+			continue
+		}
+		b, err := json.Marshal(job{
+			TenantID:       tenantID,
+			RequestID:      logging.RequestID(ctx),
+			Trace:          tracing.Inject(ctx),
+			SubscriptionID: dest.id,
+			Event:          &out,
+		})
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, b)
+	}
+	if len(jobs) == 0 {
+		return nil
+	}
+	return q.redis.LPush(ctx, q.queueKey, jobs...).Err()
+}
+
+type QueueStats struct {
+	// Ready jobs wait for a worker; Delayed ones wait for their retry time.
+	Ready   int64
+	Delayed int64
+	// LastHeartbeat is zero when no worker has run yet.
+	LastHeartbeat time.Time
+}
+
+func (q *Queue) Stats(ctx context.Context) (QueueStats, error) {
+	var stats QueueStats
+	if q == nil || q.redis == nil {
+		return stats, nil
+	}
+	if beat := q.heartbeat.Load(); beat != 0 {
+		stats.LastHeartbeat = time.Unix(0, beat)
+	}
+	pipe := q.redis.Pipeline()
+	ready := pipe.LLen(ctx, q.queueKey)
+	delayed := pipe.ZCard(ctx, q.delayedKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return stats, err
+	}
+	stats.Ready, stats.Delayed = ready.Val(), delayed.Val()
+	return stats, nil
+}
+
+// Depth returns the number of jobs waiting in the queue.
+func (q *Queue) Depth(ctx context.Context) (int64, error) {
+	if q == nil || q.redis == nil {
+		return 0, nil
+	}
+	return q.redis.LLen(ctx, q.queueKey).Result()
+}
+
+// QueuedJob describes a waiting delivery for operators. Kind is the event
+// type, "batch" for flushed batches or "payload" for pre-event jobs.
+type QueuedJob struct {
+	State          string     `json:"state"`
+	TenantID       int64      `json:"tenant_id"`
+	SubscriptionID int64      `json:"subscription_id"`
+	Kind           string     `json:"kind"`
+	EventIDs       []string   `json:"event_ids,omitempty"`
+	Attempt        int        `json:"attempt"`
+	DueAt          *time.Time `json:"due_at,omitempty"`
 }
 
+// Peek returns up to limit ready jobs in delivery order followed by up to
+// limit retries ordered by due time. It does not modify the queue.
+func (q *Queue) Peek(ctx context.Context, limit int64) ([]QueuedJob, error) {
+	if q == nil || q.redis == nil || limit <= 0 {
+		return nil, nil
+	}
+	pipe := q.redis.Pipeline()
+	ready := pipe.LRange(ctx, q.queueKey, -limit, -1)
+	delayed := pipe.ZRangeWithScores(ctx, q.delayedKey, 0, limit-1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	out := make([]QueuedJob, 0, len(ready.Val())+len(delayed.Val()))
+	// Workers pop from the right, so the list tail is delivered first.
+	items := ready.Val()
+	for i := len(items) - 1; i >= 0; i-- {
+		out = append(out, describeJob("ready", items[i], nil))
+	}
+	for _, z := range delayed.Val() {
+		due := time.UnixMilli(int64(z.Score))
+		raw, _ := z.Member.(string)
+		out = append(out, describeJob("delayed", raw, &due))
+	}
+	return out, nil
+}
+
+func describeJob(state, raw string, due *time.Time) QueuedJob {
+	out := QueuedJob{State: state, Kind: "unknown", DueAt: due}
+	var j job
+	if err := json.Unmarshal([]byte(raw), &j); err != nil {
+		return out
+	}
+	out.TenantID = j.TenantID
+	if out.TenantID == 0 {
+		out.TenantID = tenant.DefaultID
+	}
+	out.SubscriptionID = j.SubscriptionID
+	out.Attempt = j.Attempt
+	switch {
+	case j.Event != nil:
+		out.Kind = string(j.Event.Type)
+		out.EventIDs = []string{j.Event.ID}
+	case len(j.Events) > 0 || len(j.Batch) > 0:
+		out.Kind = "batch"
+		for _, e := range unbatch(j).Events {
+			out.EventIDs = append(out.EventIDs, e.ID)
+		}
+	case j.Payload != nil:
+		out.Kind = "payload"
+	}
+	return out
+}
+
+// Run starts the workers and the retry and batch promoter and blocks until ctx
+// is done. Deliveries already in progress are completed before Run returns;
+// everything else, pending batches included, stays in Redis.
 func (q *Queue) Run(ctx context.Context) {
-	if q == nil || q.redis == nil || q.webhookURL == "" {
+	if q == nil || q.redis == nil {
 		return
 	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		q.promote(ctx)
+	}()
+	for i := 0; i < q.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (q *Queue) work(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
 		}
+		q.heartbeat.Store(time.Now().UnixNano())
 
 		items, err := q.redis.BRPop(ctx, 2*time.Second, q.queueKey).Result()
 		if err == redis.Nil || err == context.DeadlineExceeded {
@@ -88,52 +368,383 @@ func (q *Queue) Run(ctx context.Context) {
 
 		var j job
 		if err := json.Unmarshal([]byte(items[1]), &j); err != nil {
+			q.log.ErrorContext(ctx, "drop malformed webhook job", "err", err)
 			continue
 		}
+		if j.Event == nil && j.Payload != nil {
+			event, err := payloadEvent(*j.Payload)
+			if err != nil {
+				q.log.ErrorContext(ctx, "drop legacy webhook job", "err", err)
+				continue
+			}
+			j.Event, j.Payload = &event, nil
+		}
+		j = unbatch(j)
+		if j.Event == nil && len(j.Events) == 0 {
+			continue
+		}
+
+		// A popped job is finished even if shutdown starts meanwhile; the
+		// delivery itself is bounded by RequestTimeout.
+		q.process(context.WithoutCancel(ctx), j)
+	}
+}
+
+// promoteDue moves retries that are due from the delayed set to the queue.
+var promoteDue = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, item in ipairs(due) do
+  redis.call('ZREM', KEYS[1], item)
+  redis.call('LPUSH', KEYS[2], item)
+end
+return #due
+`)
+
+const (
+	promoteInterval = 250 * time.Millisecond
+	promoteBatch    = 100
+)
 
-		if err := q.send(ctx, j.Payload); err != nil {
-			if j.Attempt+1 <= q.maxRetries {
-				j.Attempt++
-				q.scheduleRetry(ctx, j)
+func (q *Queue) promote(ctx context.Context) {
+	ticker := time.NewTicker(promoteInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for {
+			n, err := promoteDue.Run(ctx, q.redis, []string{q.delayedKey, q.queueKey},
+				time.Now().UnixMilli(), promoteBatch).Int()
+			if err != nil {
+				if ctx.Err() == nil {
+					q.log.WarnContext(ctx, "promote webhook retries failed", "err", err)
+				}
+				break
 			}
+			if n < promoteBatch {
+				break
+			}
+		}
+		if err := q.flushDue(ctx); err != nil && ctx.Err() == nil {
+			q.log.WarnContext(ctx, "flush webhook batches failed", "err", err)
 		}
 	}
 }
 
-func (q *Queue) send(ctx context.Context, payload Payload) error {
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return err
+func (q *Queue) process(ctx context.Context, j job) {
+	if j.TenantID == 0 {
+		j.TenantID = tenant.DefaultID
+	}
+	ctx = tenant.WithID(ctx, j.TenantID)
+	if j.RequestID != "" {
+		ctx = logging.WithRequestID(ctx, j.RequestID)
+	}
+	ctx = tracing.Extract(ctx, j.Trace)
+	dest, ok := q.resolve(ctx, j.SubscriptionID)
+	if !ok {
+		q.log.DebugContext(ctx, "drop webhook job for inactive destination", "subscription_id", j.SubscriptionID)
+		return
+	}
+	if j.Event != nil && dest.batching() {
+		if err := q.addToBatch(ctx, dest, j); err != nil {
+			// Deliver on its own rather than lose it.
+			q.log.WarnContext(ctx, "buffer webhook batch member failed", "subscription_id", dest.id, "err", err)
+		} else {
+			return
+		}
+	}
+	sem, br := q.destination(dest)
+
+	select {
+	case sem <- struct{}{}:
+	default:
+		// Destination is saturated: retry shortly instead of blocking this worker.
+		q.schedule(ctx, j, jitter(100*time.Millisecond))
+		return
+	}
+	if ok, wait := br.allow(time.Now()); !ok {
+		<-sem
+		q.schedule(ctx, j, wait)
+		return
+	}
+	res, err := q.send(ctx, dest, j)
+	<-sem
+	q.opts.Metrics.ObserveDelivery(dest.label(), res.Latency, err != nil)
+
+	if err == nil {
+		br.success()
+		q.log.DebugContext(ctx, "webhook delivered",
+			"subscription_id", dest.id, "status", res.StatusCode, "latency_ms", res.Latency.Milliseconds(), "attempt", j.Attempt)
+		return
+	}
+	br.failure(time.Now())
+
+	if j.Attempt+1 > q.opts.MaxRetries {
+		q.log.ErrorContext(ctx, "webhook delivery failed, giving up",
+			"subscription_id", dest.id, "status", res.StatusCode, "attempt", j.Attempt, "err", err)
+		return
+	}
+	q.log.WarnContext(ctx, "webhook delivery failed, will retry",
+		"subscription_id", dest.id, "status", res.StatusCode, "attempt", j.Attempt, "err", err)
+	j.Attempt++
+	q.schedule(ctx, j, q.retryDelay(j.Attempt, err))
+}
+
+func (q *Queue) resolve(ctx context.Context, subscriptionID int64) (destination, bool) {
+	if subscriptionID == 0 {
+		tenantID, ok := tenant.ID(ctx)
+		return q.legacyDestination(), ok && q.legacyEnabled(tenantID)
+	}
+	if q.subs == nil {
+		return destination{}, false
+	}
+	sub, err := q.subs.GetByID(ctx, subscriptionID)
+	if err != nil || !sub.IsActive {
+		return destination{}, false
+	}
+	return q.subscriptionDestination(sub), true
+}
+
+// legacyEnabled reports whether WEBHOOK_URL receives events of the tenant; it
+// predates tenants and only ever gets the default tenant's.
+func (q *Queue) legacyEnabled(tenantID int64) bool {
+	return q.webhookURL != "" && tenantID == tenant.DefaultID
+}
+
+func (q *Queue) legacyDestination() destination {
+	dest := destination{
+		url:            q.webhookURL,
+		format:         FormatLegacy,
+		secret:         q.opts.Secret,
+		concurrency:    q.opts.DestinationConcurrency,
+		digestCooldown: q.opts.DigestCooldown,
+	}
+	dest.batchWindow, dest.batchSize = batchSettings(q.opts.BatchWindow, q.opts.BatchMaxSize)
+	return dest
+}
+
+func (q *Queue) subscriptionDestination(sub Subscription) destination {
+	dest := destination{
+		id:          sub.ID,
+		url:         sub.URL,
+		format:      sub.Format,
+		secret:      sub.Secret,
+		concurrency: q.opts.DestinationConcurrency,
+	}
+	if sub.MaxConcurrency != nil && *sub.MaxConcurrency > 0 {
+		dest.concurrency = *sub.MaxConcurrency
+	}
+	if sub.DigestCooldown != nil {
+		dest.digestCooldown = time.Duration(*sub.DigestCooldown) * time.Second
+	}
+	var (
+		window time.Duration
+		size   int
+	)
+	if sub.BatchWindowMs != nil {
+		window = time.Duration(*sub.BatchWindowMs) * time.Millisecond
+	}
+	if sub.BatchMaxSize != nil {
+		size = *sub.BatchMaxSize
+	}
+	if sub.Format != FormatCloudEventsBinary {
+		dest.batchWindow, dest.batchSize = batchSettings(window, size)
+	}
+	return dest
+}
+
+// batchSettings fills in defaults when only one of window and size is set.
+// A zero size means batching is disabled.
+func batchSettings(window time.Duration, size int) (time.Duration, int) {
+	if window <= 0 && size <= 0 {
+		return 0, 0
+	}
+	if window <= 0 {
+		window = defaultBatchWindow
+	}
+	if size <= 0 {
+		size = defaultBatchMaxSize
+	}
+	return window, size
+}
+
+func (q *Queue) destination(dest destination) (chan struct{}, *breaker) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	state, ok := q.dests[dest.id]
+	if !ok {
+		state = &destState{breaker: newBreaker(q.opts.BreakerThreshold, q.opts.BreakerCooldown)}
+		q.dests[dest.id] = state
+	}
+	if state.sem == nil || cap(state.sem) != dest.concurrency {
+		state.sem = make(chan struct{}, dest.concurrency)
+	}
+	return state.sem, state.breaker
+}
+
+type DeliveryResult struct {
+	StatusCode int
+	Latency    time.Duration
+	Body       string
+}
+
+const maxResultBody = 4 << 10
+
+func (q *Queue) send(ctx context.Context, dest destination, j job) (res DeliveryResult, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "webhook send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("webhook.destination", dest.label()),
+			attribute.Int("webhook.attempt", j.Attempt),
+			attribute.Int("webhook.events", max(len(j.Events), 1)),
+		))
+	defer func() {
+		if res.StatusCode != 0 {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(res.StatusCode))
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	var req *http.Request
+	if len(j.Events) > 0 {
+		req, err = newBatchRequest(ctx, dest, j.Events)
+	} else {
+		req, err = newDeliveryRequest(ctx, dest, *j.Event)
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, q.webhookURL, bytes.NewReader(body))
 	if err != nil {
-		return err
+		return DeliveryResult{}, err
+	}
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
 	resp, err := q.client.Do(req)
+	res = DeliveryResult{Latency: time.Since(start)}
 	if err != nil {
-		return err
+		return res, err
 	}
 	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResultBody))
+	res.StatusCode = resp.StatusCode
+	res.Body = string(body)
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return errHTTPStatus(resp.StatusCode)
+		return res, &deliveryError{
+			status:     resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	}
-	return nil
+	return res, nil
 }
 
-func (q *Queue) scheduleRetry(ctx context.Context, j job) {
-	delay := q.retryBase * time.Duration(1<<uint(j.Attempt-1))
-	time.AfterFunc(delay, func() {
-		b, err := json.Marshal(j)
-		if err != nil {
-			return
-		}
-		_ = q.redis.LPush(ctx, q.queueKey, b).Err()
+// Ping synchronously delivers a ping event to a subscription, bypassing the
+// queue, breaker and retries.
+func (q *Queue) Ping(ctx context.Context, sub Subscription) (DeliveryResult, error) {
+	return q.ping(ctx, q.subscriptionDestination(sub))
+}
+
+// PingURL delivers a ping event to an arbitrary receiver.
+func (q *Queue) PingURL(ctx context.Context, target string, format Format, secret string) (DeliveryResult, error) {
+	return q.ping(ctx, destination{url: target, format: format, secret: secret})
+}
+
+func (q *Queue) ping(ctx context.Context, dest destination) (DeliveryResult, error) {
+	event, err := NewEvent(EventPing, map[string]any{
+		"subscription_id": dest.id,
+		"message":         "ping",
 	})
+	if err != nil {
+		return DeliveryResult{}, err
+	}
+	return q.send(ctx, dest, job{SubscriptionID: dest.id, Event: &event})
+}
+
+// backoff returns an exponential delay for the given attempt, capped at
+// RetryMax and randomised with equal jitter so receivers recovering from an
+// outage are not hit by synchronised retries.
+func (q *Queue) backoff(attempt int) time.Duration {
+	delay := q.opts.RetryBase
+	for i := 1; i < attempt && (q.opts.RetryMax <= 0 || delay < q.opts.RetryMax); i++ {
+		delay *= 2
+	}
+	if q.opts.RetryMax > 0 && delay > q.opts.RetryMax {
+		delay = q.opts.RetryMax
+	}
+	return jitter(delay)
 }
 
-type errHTTPStatus int
+// retryDelay is the backoff for attempt, or the receiver's Retry-After if that
+// is longer.
+func (q *Queue) retryDelay(attempt int, err error) time.Duration {
+	delay := q.backoff(attempt)
+	var statusErr *deliveryError
+	if errors.As(err, &statusErr) && statusErr.retryAfter > delay {
+		delay = statusErr.retryAfter
+	}
+	return delay
+}
+
+// schedule stores the job in the delayed set, scored by when it is due, so
+// retries survive restarts. It is written even when ctx is already cancelled.
+func (q *Queue) schedule(ctx context.Context, j job, delay time.Duration) {
+	b, err := json.Marshal(j)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Second)
+	defer cancel()
+	due := time.Now().Add(delay).UnixMilli()
+	if err := q.redis.ZAdd(ctx, q.delayedKey, redis.Z{Score: float64(due), Member: b}).Err(); err != nil {
+		q.log.ErrorContext(ctx, "schedule webhook retry failed", "subscription_id", j.SubscriptionID, "attempt", j.Attempt, "err", err)
+	}
+}
+
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+func parseRetryAfter(raw string, now time.Time) time.Duration {
+	if raw == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(raw); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(raw); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+func payloadEvent(payload Payload) (Event, error) {
+	event, err := NewEvent(EventLocationDangerous, payload)
+	if err != nil {
+		return Event{}, err
+	}
+	event.OccurredAt = payload.CreatedAt
+	return event, nil
+}
+
+type deliveryError struct {
+	status     int
+	retryAfter time.Duration
+}
 
-func (e errHTTPStatus) Error() string {
-	return "webhook status " + http.StatusText(int(e))
+func (e *deliveryError) Error() string {
+	return "webhook status " + http.StatusText(e.status)
 }
@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	q := &Queue{opts: QueueOptions{RetryBase: time.Second, RetryMax: 10 * time.Second}}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{60, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 50; i++ {
+			if got := q.backoff(tt.attempt); got < tt.want/2 || got > tt.want {
+				t.Fatalf("backoff(%d) = %s, want within [%s, %s]", tt.attempt, got, tt.want/2, tt.want)
+			}
+		}
+	}
+}
+
+func TestRetryDelayHonoursRetryAfter(t *testing.T) {
+	q := &Queue{opts: QueueOptions{RetryBase: time.Second, RetryMax: 10 * time.Second}}
+	if got := q.retryDelay(1, &deliveryError{status: http.StatusTooManyRequests, retryAfter: time.Minute}); got != time.Minute {
+		t.Fatalf("longer Retry-After: delay = %s", got)
+	}
+	if got := q.retryDelay(4, &deliveryError{status: http.StatusServiceUnavailable, retryAfter: time.Millisecond}); got < 4*time.Second {
+		t.Fatalf("shorter Retry-After replaced the backoff: delay = %s", got)
+	}
+	if got := q.retryDelay(1, errors.New("connection refused")); got > time.Second {
+		t.Fatalf("network error: delay = %s", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		raw  string
+		want time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"0", 0},
+		{"-5", 0},
+		{"soon", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.raw, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package webhook
+
+import (
+	"context"
+	"sync"
+)
+
+// Enqueuer accepts events for delivery. Queue delivers them to subscribers;
+// Recorder keeps them in memory for tests.
+type Enqueuer interface {
+	Publish(ctx context.Context, event Event) error
+	Enqueue(ctx context.Context, payload Payload) error
+}
+
+// Recorder is an Enqueuer that stores events instead of delivering them.
+type Recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) Publish(_ context.Context, event Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *Recorder) Enqueue(ctx context.Context, payload Payload) error {
+	event, err := payloadEvent(payload)
+	if err != nil {
+		return err
+	}
+	return r.Publish(ctx, event)
+}
+
+// Events returns the events published so far in order.
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"RedColarTest/internal/common"
+	"RedColarTest/internal/logging"
+	tenant "RedColarTest/internal/tenants/domain"
+	"RedColarTest/internal/webhook"
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const subscriptionColumns = `id, tenant_id, url, format, coalesce(secret, ''), max_concurrency, batch_window_ms, batch_max_size, digest_cooldown_seconds, is_active, created_at, updated_at`
+
+type SubscriptionRepo struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewSubscriptionRepo(db *pgxpool.Pool, logger *slog.Logger) *SubscriptionRepo {
+	return &SubscriptionRepo{db: db, log: logging.OrDiscard(logger)}
+}
+
+func (r *SubscriptionRepo) Create(ctx context.Context, in webhook.Subscription) (webhook.Subscription, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return webhook.Subscription{}, cerr
+	}
+	const q = `
+insert into webhook_subscriptions (url, format, max_concurrency, batch_window_ms, batch_max_size, digest_cooldown_seconds, is_active, secret, tenant_id)
+values ($1, $2, $3, $4, $5, $6, $7, nullif($8, ''), $9)
+returning ` + subscriptionColumns + `;
+`
+	out, err := scanSubscription(r.db.QueryRow(ctx, q,
+		in.URL,
+		in.Format,
+		in.MaxConcurrency,
+		in.BatchWindowMs,
+		in.BatchMaxSize,
+		in.DigestCooldown,
+		in.IsActive,
+		in.Secret,
+		tenantID,
+	))
+	if err != nil {
+		return webhook.Subscription{}, common.Internal(ctx, r.log, "SubscriptionRepo.Create", err)
+	}
+	return out, nil
+}
+
+func (r *SubscriptionRepo) GetByID(ctx context.Context, id int64) (webhook.Subscription, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return webhook.Subscription{}, cerr
+	}
+	const q = `select ` + subscriptionColumns + ` from webhook_subscriptions where id = $1 and tenant_id = $2;`
+	out, err := scanSubscription(r.db.QueryRow(ctx, q, id, tenantID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return webhook.Subscription{}, common.NewError(common.CodeNotFound, err.Error())
+	}
+	if err != nil {
+		return webhook.Subscription{}, common.Internal(ctx, r.log, "SubscriptionRepo.GetByID", err)
+	}
+	return out, nil
+}
+
+func (r *SubscriptionRepo) List(ctx context.Context) ([]webhook.Subscription, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return nil, cerr
+	}
+	const q = `select ` + subscriptionColumns + ` from webhook_subscriptions where tenant_id = $1 order by id;`
+	rows, err := r.db.Query(ctx, q, tenantID)
+	if err != nil {
+		return nil, common.Internal(ctx, r.log, "SubscriptionRepo.List", err)
+	}
+	return r.scanSubscriptions(ctx, rows)
+}
+
+func (r *SubscriptionRepo) ListActive(ctx context.Context) ([]webhook.Subscription, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return nil, cerr
+	}
+	const q = `select ` + subscriptionColumns + ` from webhook_subscriptions where tenant_id = $1 and is_active = true order by id;`
+	rows, err := r.db.Query(ctx, q, tenantID)
+	if err != nil {
+		return nil, common.Internal(ctx, r.log, "SubscriptionRepo.ListActive", err)
+	}
+	return r.scanSubscriptions(ctx, rows)
+}
+
+func (r *SubscriptionRepo) Update(ctx context.Context, id int64, in webhook.Subscription) (webhook.Subscription, *common.Error) {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return webhook.Subscription{}, cerr
+	}
+	const q = `
+update webhook_subscriptions
+set url = $2,
+    format = $3,
+    max_concurrency = $4,
+    batch_window_ms = $5,
+    batch_max_size = $6,
+    digest_cooldown_seconds = $7,
+    is_active = $8,
+    secret = coalesce(nullif($9, ''), secret),
+    updated_at = now()
+where id = $1 and tenant_id = $10
+returning ` + subscriptionColumns + `;
+`
+	out, err := scanSubscription(r.db.QueryRow(ctx, q,
+		id,
+		in.URL,
+		in.Format,
+		in.MaxConcurrency,
+		in.BatchWindowMs,
+		in.BatchMaxSize,
+		in.DigestCooldown,
+		in.IsActive,
+		in.Secret,
+		tenantID,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return webhook.Subscription{}, common.NewError(common.CodeNotFound, err.Error())
+	}
+	if err != nil {
+		return webhook.Subscription{}, common.Internal(ctx, r.log, "SubscriptionRepo.Update", err)
+	}
+	return out, nil
+}
+
+func (r *SubscriptionRepo) Delete(ctx context.Context, id int64) *common.Error {
+	tenantID, cerr := tenant.Require(ctx)
+	if cerr != nil {
+		return cerr
+	}
+	tag, err := r.db.Exec(ctx, `delete from webhook_subscriptions where id = $1 and tenant_id = $2;`, id, tenantID)
+	if err != nil {
+		return common.Internal(ctx, r.log, "SubscriptionRepo.Delete", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return common.NewError(common.CodeNotFound, "subscription not found")
+	}
+	return nil
+}
+
+func scanSubscription(row pgx.Row) (webhook.Subscription, error) {
+	var out webhook.Subscription
+	err := row.Scan(
+		&out.ID,
+		&out.TenantID,
+		&out.URL,
+		&out.Format,
+		&out.Secret,
+		&out.MaxConcurrency,
+		&out.BatchWindowMs,
+		&out.BatchMaxSize,
+		&out.DigestCooldown,
+		&out.IsActive,
+		&out.CreatedAt,
+		&out.UpdatedAt,
+	)
+	out.HasSecret = out.Secret != ""
+	return out, err
+}
+
+func (r *SubscriptionRepo) scanSubscriptions(ctx context.Context, rows pgx.Rows) ([]webhook.Subscription, *common.Error) {
+	defer rows.Close()
+
+	items := make([]webhook.Subscription, 0)
+	for rows.Next() {
+		it, err := scanSubscription(rows)
+		if err != nil {
+			return nil, common.Internal(ctx, r.log, "SubscriptionRepo.scanSubscriptions", err)
+		}
+		items = append(items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, common.Internal(ctx, r.log, "SubscriptionRepo.scanSubscriptions", err)
+	}
+	return items, nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"RedColarTest/internal/common"
+	"RedColarTest/internal/webhook"
+	"RedColarTest/internal/webhook/repository"
+	"context"
+	"fmt"
+	"net/url"
+)
+
+type SubscriptionService struct {
+	repo     *repository.SubscriptionRepo
+	webhookQ *webhook.Queue
+}
+
+func NewSubscriptionService(repo *repository.SubscriptionRepo, webhookQ *webhook.Queue) *SubscriptionService {
+	return &SubscriptionService{repo: repo, webhookQ: webhookQ}
+}
+
+func (s *SubscriptionService) Create(ctx context.Context, in webhook.Subscription) (webhook.Subscription, *common.Error) {
+	if in.Format == "" {
+		in.Format = webhook.FormatLegacy
+	}
+	if err := validateSubscription(in); err != nil {
+		return webhook.Subscription{}, common.NewError(common.CodeNotValid, err.Error())
+	}
+	return s.repo.Create(ctx, in)
+}
+
+func (s *SubscriptionService) GetByID(ctx context.Context, id int64) (webhook.Subscription, *common.Error) {
+	if id <= 0 {
+		return webhook.Subscription{}, common.NewError(common.CodeNotValid, fmt.Sprintf("Subscription with id %d not found", id))
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *SubscriptionService) List(ctx context.Context) ([]webhook.Subscription, *common.Error) {
+	return s.repo.List(ctx)
+}
+
+func (s *SubscriptionService) Update(ctx context.Context, id int64, in webhook.Subscription) (webhook.Subscription, *common.Error) {
+	if id <= 0 {
+		return webhook.Subscription{}, common.NewError(common.CodeNotValid, fmt.Sprintf("Subscription with id %d not found", id))
+	}
+	if in.Format == "" {
+		in.Format = webhook.FormatLegacy
+	}
+	if err := validateSubscription(in); err != nil {
+		return webhook.Subscription{}, common.NewError(common.CodeNotValid, err.Error())
+	}
+	return s.repo.Update(ctx, id, in)
+}
+
+func (s *SubscriptionService) Delete(ctx context.Context, id int64) *common.Error {
+	if id <= 0 {
+		return common.NewError(common.CodeNotValid, fmt.Sprintf("Subscription with id %d not found", id))
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// Ping reports the receiver's answer whatever its status code; only transport
+// failures are returned as errors.
+func (s *SubscriptionService) Ping(ctx context.Context, id int64) (webhook.DeliveryResult, *common.Error) {
+	sub, err := s.GetByID(ctx, id)
+	if err != nil {
+		return webhook.DeliveryResult{}, err
+	}
+	res, deliveryErr := s.webhookQ.Ping(ctx, sub)
+	if deliveryErr != nil && res.StatusCode == 0 {
+		return res, common.NewError(common.CodeUnavailable, deliveryErr.Error())
+	}
+	return res, nil
+}
+
+func validateSubscription(in webhook.Subscription) error {
+	u, err := url.Parse(in.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) url")
+	}
+	if !in.Format.Valid() {
+		return fmt.Errorf("unknown format %q", in.Format)
+	}
+	if in.MaxConcurrency != nil && *in.MaxConcurrency <= 0 {
+		return fmt.Errorf("max_concurrency must be > 0")
+	}
+	if in.BatchWindowMs != nil && *in.BatchWindowMs <= 0 {
+		return fmt.Errorf("batch_window_ms must be > 0")
+	}
+	if in.BatchMaxSize != nil && *in.BatchMaxSize <= 0 {
+		return fmt.Errorf("batch_max_size must be > 0")
+	}
+	if in.DigestCooldown != nil && *in.DigestCooldown <= 0 {
+		return fmt.Errorf("digest_cooldown_seconds must be > 0")
+	}
+	if in.Format == webhook.FormatCloudEventsBinary && (in.BatchWindowMs != nil || in.BatchMaxSize != nil) {
+		return fmt.Errorf("batching is not supported for %s", webhook.FormatCloudEventsBinary)
+	}
+	return nil
+}
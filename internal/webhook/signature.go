@@ -0,0 +1,28 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// signRequest sets X-Webhook-Signature to "t=<unix>,v1=<hex>", where v1 is
+// HMAC-SHA256 over "<unix>.<body>" keyed by the destination secret.
+func signRequest(req *http.Request, body []byte, secret string, now time.Time) {
+	if secret == "" {
+		return
+	}
+	ts := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("X-Webhook-Signature", "t="+ts+",v1="+sign(secret, ts, body))
+}
+
+func sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
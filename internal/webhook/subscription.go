@@ -0,0 +1,45 @@
+package webhook
+
+import (
+	"RedColarTest/internal/common"
+	"context"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatLegacy                Format = "legacy"
+	FormatCloudEventsStructured Format = "cloudevents-structured"
+	FormatCloudEventsBinary     Format = "cloudevents-binary"
+)
+
+func (f Format) Valid() bool {
+	switch f {
+	case FormatLegacy, FormatCloudEventsStructured, FormatCloudEventsBinary:
+		return true
+	}
+	return false
+}
+
+type Subscription struct {
+	ID             int64     `json:"id"`
+	TenantID       int64     `json:"tenant_id"`
+	URL            string    `json:"url"`
+	Format         Format    `json:"format"`
+	Secret         string    `json:"-"`
+	HasSecret      bool      `json:"has_secret"`
+	MaxConcurrency *int      `json:"max_concurrency,omitempty"`
+	BatchWindowMs  *int      `json:"batch_window_ms,omitempty"`
+	BatchMaxSize   *int      `json:"batch_max_size,omitempty"`
+	DigestCooldown *int      `json:"digest_cooldown_seconds,omitempty"`
+	IsActive       bool      `json:"is_active"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type SubscriptionStore interface {
+	GetByID(ctx context.Context, id int64) (Subscription, *common.Error)
+
+	ListActive(ctx context.Context) ([]Subscription, *common.Error)
+}
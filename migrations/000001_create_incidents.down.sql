@@ -1 +1,4 @@
-drop table if exists incidents;
\ No newline at end of file
+drop index if exists idx_incidents_active_lat_lon;
+drop index if exists idx_incidents_is_active;
+
+drop table if exists incidents;
@@ -0,0 +1,4 @@
+drop index if exists idx_incidents_active_expires_at;
+
+alter table incidents
+    drop column if exists expires_at;
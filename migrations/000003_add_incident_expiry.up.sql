@@ -0,0 +1,8 @@
+alter table incidents
+    add column if not exists expires_at timestamptz;
+
+create index if not exists idx_incidents_active_expires_at
+    on incidents (expires_at)
+    where is_active = true and expires_at is not null;
+
+comment on column incidents.expires_at is 'время автоматического закрытия инцидента';
@@ -0,0 +1,19 @@
+create table if not exists webhook_subscriptions
+(
+    id bigserial primary key,
+    url text not null,
+    format varchar(32) not null default 'legacy',
+    is_active boolean not null default true,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    constraint webhook_subscriptions_format check (format in ('legacy', 'cloudevents-structured', 'cloudevents-binary'))
+);
+
+create index if not exists idx_webhook_subscriptions_is_active
+    on webhook_subscriptions (is_active);
+
+comment on table webhook_subscriptions is 'получатели вебхуков';
+
+comment on column webhook_subscriptions.url is 'адрес, на который отправляются события';
+comment on column webhook_subscriptions.format is 'формат доставки: legacy, cloudevents-structured или cloudevents-binary';
+comment on column webhook_subscriptions.is_active is 'признак активности подписки';
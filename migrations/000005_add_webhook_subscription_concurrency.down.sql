@@ -0,0 +1,2 @@
+alter table webhook_subscriptions
+    drop column if exists max_concurrency;
@@ -0,0 +1,4 @@
+alter table webhook_subscriptions
+    add column if not exists max_concurrency integer check (max_concurrency > 0);
+
+comment on column webhook_subscriptions.max_concurrency is 'максимум одновременных доставок получателю; null — значение по умолчанию';
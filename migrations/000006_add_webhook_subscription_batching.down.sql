@@ -0,0 +1,4 @@
+alter table webhook_subscriptions
+    drop column if exists digest_cooldown_seconds,
+    drop column if exists batch_max_size,
+    drop column if exists batch_window_ms;
@@ -0,0 +1,8 @@
+alter table webhook_subscriptions
+    add column if not exists batch_window_ms integer check (batch_window_ms > 0),
+    add column if not exists batch_max_size integer check (batch_max_size > 0),
+    add column if not exists digest_cooldown_seconds integer check (digest_cooldown_seconds > 0);
+
+comment on column webhook_subscriptions.batch_window_ms is 'окно накопления событий в пачку, мс; null — без пакетной доставки';
+comment on column webhook_subscriptions.batch_max_size is 'максимальный размер пачки событий';
+comment on column webhook_subscriptions.digest_cooldown_seconds is 'период, в течение которого повторные оповещения по паре пользователь+инцидент схлопываются';
@@ -0,0 +1,4 @@
+alter table webhook_subscriptions
+    add column if not exists secret varchar(256);
+
+comment on column webhook_subscriptions.secret is 'секрет для HMAC-подписи доставок (X-Webhook-Signature)';
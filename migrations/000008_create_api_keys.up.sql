@@ -0,0 +1,25 @@
+create table if not exists api_keys
+(
+    id bigserial primary key,
+    name varchar(200) not null,
+    owner varchar(200) not null,
+    prefix varchar(16) not null,
+    key_hash char(64) not null,
+    scopes text[] not null default '{}',
+    expires_at timestamptz,
+    last_used_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz not null default now(),
+    constraint api_keys_prefix_unique unique (prefix)
+);
+
+comment on table api_keys is 'ключи доступа операторов';
+
+comment on column api_keys.name is 'название ключа';
+comment on column api_keys.owner is 'владелец ключа';
+comment on column api_keys.prefix is 'открытая часть ключа для поиска';
+comment on column api_keys.key_hash is 'sha-256 от полного ключа, hex';
+comment on column api_keys.scopes is 'разрешения ключа, например incidents:read';
+comment on column api_keys.expires_at is 'время истечения ключа; null — бессрочный';
+comment on column api_keys.last_used_at is 'время последнего использования';
+comment on column api_keys.revoked_at is 'время отзыва ключа';
@@ -0,0 +1,5 @@
+alter table api_keys
+    drop constraint if exists api_keys_role;
+
+alter table api_keys
+    drop column if exists role;
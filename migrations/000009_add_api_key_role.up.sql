@@ -0,0 +1,7 @@
+alter table api_keys
+    add column if not exists role varchar(32);
+
+alter table api_keys
+    add constraint api_keys_role check (role in ('viewer', 'dispatcher', 'admin'));
+
+comment on column api_keys.role is 'роль ключа: viewer, dispatcher или admin; права роли добавляются к scopes';
@@ -0,0 +1,20 @@
+drop index if exists idx_webhook_subscriptions_tenant_is_active;
+drop index if exists idx_location_checks_tenant_created_at;
+drop index if exists idx_incidents_tenant_active_lat_lon;
+drop index if exists idx_incidents_tenant_is_active;
+
+create index if not exists idx_incidents_is_active
+    on incidents (is_active);
+
+create index if not exists idx_incidents_active_lat_lon
+    on incidents (is_active, latitude, longitude);
+
+create index if not exists idx_location_checks_created_at
+    on location_checks (created_at);
+
+alter table webhook_subscriptions drop column if exists tenant_id;
+alter table api_keys drop column if exists tenant_id;
+alter table location_checks drop column if exists tenant_id;
+alter table incidents drop column if exists tenant_id;
+
+drop table if exists tenants;
@@ -0,0 +1,57 @@
+create table if not exists tenants
+(
+    id bigserial primary key,
+    slug varchar(64) not null,
+    name varchar(200) not null,
+    is_active boolean not null default true,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    constraint tenants_slug_unique unique (slug)
+);
+
+comment on table tenants is 'организации (города), данные которых изолированы друг от друга';
+
+comment on column tenants.slug is 'короткий идентификатор, передаётся в заголовке X-Tenant-ID';
+comment on column tenants.name is 'название организации';
+comment on column tenants.is_active is 'признак активности';
+
+insert into tenants (id, slug, name)
+values (1, 'default', 'Default')
+on conflict (id) do nothing;
+
+select setval(pg_get_serial_sequence('tenants', 'id'), greatest((select max(id) from tenants), 1));
+
+alter table incidents
+    add column if not exists tenant_id bigint not null default 1 references tenants (id);
+alter table location_checks
+    add column if not exists tenant_id bigint not null default 1 references tenants (id);
+alter table api_keys
+    add column if not exists tenant_id bigint not null default 1 references tenants (id);
+alter table webhook_subscriptions
+    add column if not exists tenant_id bigint not null default 1 references tenants (id);
+
+alter table incidents alter column tenant_id drop default;
+alter table location_checks alter column tenant_id drop default;
+alter table api_keys alter column tenant_id drop default;
+alter table webhook_subscriptions alter column tenant_id drop default;
+
+drop index if exists idx_incidents_is_active;
+drop index if exists idx_incidents_active_lat_lon;
+drop index if exists idx_location_checks_created_at;
+
+create index if not exists idx_incidents_tenant_is_active
+    on incidents (tenant_id, is_active);
+
+create index if not exists idx_incidents_tenant_active_lat_lon
+    on incidents (tenant_id, is_active, latitude, longitude);
+
+create index if not exists idx_location_checks_tenant_created_at
+    on location_checks (tenant_id, created_at);
+
+create index if not exists idx_webhook_subscriptions_tenant_is_active
+    on webhook_subscriptions (tenant_id, is_active);
+
+comment on column incidents.tenant_id is 'организация-владелец';
+comment on column location_checks.tenant_id is 'организация-владелец';
+comment on column api_keys.tenant_id is 'организация, к данным которой даёт доступ ключ';
+comment on column webhook_subscriptions.tenant_id is 'организация-владелец';
@@ -0,0 +1,3 @@
+alter table location_checks drop column if exists client_id;
+
+drop table if exists clients;
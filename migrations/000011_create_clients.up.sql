@@ -0,0 +1,24 @@
+create table if not exists clients
+(
+    id bigserial primary key,
+    tenant_id bigint not null references tenants (id),
+    client_id varchar(64) not null,
+    name varchar(200) not null,
+    secret varchar(128) not null,
+    revoked_at timestamptz,
+    created_at timestamptz not null default now(),
+    constraint clients_client_id_unique unique (client_id)
+);
+
+comment on table clients is 'зарегистрированные клиентские приложения, подписывающие проверки локации';
+
+comment on column clients.tenant_id is 'организация, к которой относится приложение';
+comment on column clients.client_id is 'открытый идентификатор, передаётся в заголовке X-Client-Id';
+comment on column clients.name is 'название приложения';
+comment on column clients.secret is 'секрет для HMAC-подписи запросов';
+comment on column clients.revoked_at is 'время отзыва; отозванные приложения не проходят проверку';
+
+alter table location_checks
+    add column if not exists client_id varchar(64);
+
+comment on column location_checks.client_id is 'приложение, подписавшее запрос; null — неподписанный запрос';